@@ -0,0 +1,153 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ALBHandler returns function suitable to use as an AWS Lambda handler with
+// github.com/aws/aws-lambda-go/lambda package for a Lambda function
+// registered as an Application Load Balancer target.
+//
+// Both single-value and multi-value headers modes of the target group are
+// supported: if the request carries multi-value headers, response is returned
+// in the same mode. Multiple Set-Cookie headers can only be sent in the
+// multi-value headers mode, which has to be enabled on the target group; in
+// the single-value mode only the first cookie is sent, and the rest are
+// dropped with a logged warning, since Set-Cookie values cannot be joined.
+//
+// Query parameters are passed by ALB the way client sent them, so they are
+// used as r.URL.RawQuery without decoding. Client address, which ALB only
+// reports in the X-Forwarded-For header, is used as r.RemoteAddr, and r.TLS
+// is set if the X-Forwarded-Proto header reports that client used HTTPS.
+//
+// Note that both request and response are fully cached in memory.
+func ALBHandler(h http.Handler, opts ...Option) func(context.Context, *events.ALBTargetGroupRequest) (*events.ALBTargetGroupResponse, error) {
+	if h == nil {
+		panic("ALBHandler called with nil argument")
+	}
+	return newHandler(h, opts).runALB
+}
+
+func (h *lambdaHandler) runALB(ctx context.Context, req *events.ALBTargetGroupRequest) (*events.ALBTargetGroupResponse, error) {
+	atomic.AddUint64(&invocations, 1)
+	multiValue := req.MultiValueHeaders != nil
+	method, ok := normalizeMethod(req.HTTPMethod, h.extraMethods)
+	if !ok {
+		return h.responseALB(errorResponse(http.StatusBadRequest), multiValue), nil
+	}
+	var headers http.Header
+	var rawQuery string
+	if multiValue {
+		headers = make(http.Header, len(req.MultiValueHeaders))
+		for k, vv := range req.MultiValueHeaders {
+			k = http.CanonicalHeaderKey(k)
+			headers[k] = append(headers[k], vv...)
+		}
+		rawQuery = albQuery(req.MultiValueQueryStringParameters)
+	} else {
+		headers = singleValueHeaders(req.Headers)
+		q := make(map[string][]string, len(req.QueryStringParameters))
+		for k, v := range req.QueryStringParameters {
+			q[k] = []string{v}
+		}
+		rawQuery = albQuery(q)
+	}
+	r := &http.Request{
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Proto:      "HTTP/1.1",
+		Method:     method,
+		URL:        &url.URL{Path: h.requestPath(method, req.Path), RawQuery: rawQuery},
+		Header:     headers,
+		Host:       h.host(headers, ""),
+		RemoteAddr: albClientIP(headers.Get("X-Forwarded-For")),
+	}
+	h.setProto(r, "")
+	setConn(r, strings.EqualFold(headers.Get("X-Forwarded-Proto"), "https"))
+	ctx = context.WithValue(ctx, albEventKey, req)
+	r = r.WithContext(h.requestContext(ctx, "", headers))
+	h.setBody(r, req.Body, req.IsBase64Encoded)
+	res := h.serve(r)
+	defer res.release()
+	if res.err != nil {
+		return nil, res.err
+	}
+	return h.responseALB(res, multiValue), nil
+}
+
+// albQuery builds raw query string from ALB query parameters. ALB passes
+// parameters the way client sent them, without decoding, so they are used
+// as is.
+func albQuery(params map[string][]string) string {
+	if len(params) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		for _, v := range params[k] {
+			if b.Len() != 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(k)
+			b.WriteByte('=')
+			b.WriteString(v)
+		}
+	}
+	return b.String()
+}
+
+// responseALB converts res to the ALB target group response.
+func (h *lambdaHandler) responseALB(res *response, multiValue bool) *events.ALBTargetGroupResponse {
+	out := &events.ALBTargetGroupResponse{
+		StatusCode:        res.status,
+		StatusDescription: h.statusDescription(res.status),
+	}
+	if multiValue {
+		out.MultiValueHeaders = make(map[string][]string, len(res.header))
+		for k, vv := range res.header {
+			out.MultiValueHeaders[k] = vv
+		}
+	} else {
+		out.Headers = make(map[string]string, len(res.header))
+		for k, vv := range res.header {
+			if strings.EqualFold(k, "Set-Cookie") && len(vv) > 1 {
+				// joined cookies are not valid, and ALB ignores
+				// MultiValueHeaders unless they are enabled on the
+				// target group
+				h.logf("apig: dropping %d of %d Set-Cookie headers: ALB target group"+
+					" needs multi-value headers enabled to send them all", len(vv)-1, len(vv))
+				out.Headers[k] = vv[0]
+				continue
+			}
+			out.Headers[k] = strings.Join(vv, ", ")
+		}
+	}
+	out.Body, out.IsBase64Encoded = h.encodeBody(res)
+	return out
+}
+
+// statusDescription returns status line for the ALB response, such as
+// "200 OK".
+func (h *lambdaHandler) statusDescription(code int) string {
+	text := http.StatusText(code)
+	if h.statusText != nil {
+		text = h.statusText(code)
+	}
+	if text == "" {
+		text = "Status"
+	}
+	return strconv.Itoa(code) + " " + text
+}
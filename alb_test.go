@@ -0,0 +1,122 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestALBStatusDescription(t *testing.T) {
+	custom := func(code int) string {
+		if code == 299 {
+			return "Partially OK"
+		}
+		return http.StatusText(code)
+	}
+	for _, tc := range []struct {
+		code int
+		opts []Option
+		want string
+	}{
+		{http.StatusOK, nil, "200 OK"},
+		{http.StatusNotFound, nil, "404 Not Found"},
+		{299, nil, "299 Status"},
+		{299, []Option{WithStatusTextFunc(custom)}, "299 Partially OK"},
+		{http.StatusOK, []Option{WithStatusTextFunc(custom)}, "200 OK"},
+		{599, []Option{WithStatusTextFunc(custom)}, "599 Status"},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(tc.code) })
+		req := &events.ALBTargetGroupRequest{
+			HTTPMethod: "GET",
+			Path:       "/",
+			Headers:    map[string]string{"host": "example.com"},
+		}
+		out, err := ALBHandler(h, tc.opts...)(context.Background(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.StatusCode != tc.code || out.StatusDescription != tc.want {
+			t.Errorf("status %d with %d options: got %d %q, want %q",
+				tc.code, len(tc.opts), out.StatusCode, out.StatusDescription, tc.want)
+		}
+	}
+}
+
+func TestTargetGroupARN(t *testing.T) {
+	const arn = "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/app/0123456789abcdef"
+	var got string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = TargetGroupARN(r.Context()) })
+	req := &events.ALBTargetGroupRequest{HTTPMethod: "GET", Path: "/"}
+	req.RequestContext.ELB.TargetGroupArn = arn
+	if _, err := ALBHandler(h)(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if got != arn {
+		t.Errorf("got ARN %q, want %q", got, arn)
+	}
+	got = "unset"
+	invoke(t, h, testEvent("GET", "/"))
+	if got != "" {
+		t.Errorf("for non-ALB request: got ARN %q, want empty", got)
+	}
+}
+
+func TestALBHandler(t *testing.T) {
+	type result struct {
+		RawQuery, RemoteAddr, Host string
+		Accept                     []string
+		TLS                        bool
+	}
+	for _, tc := range []struct {
+		name string
+		req  *events.ALBTargetGroupRequest
+		want result
+	}{
+		{"single-value", &events.ALBTargetGroupRequest{
+			HTTPMethod:            "GET",
+			Path:                  "/",
+			QueryStringParameters: map[string]string{"q": "a%20b"},
+			Headers: map[string]string{
+				"host":              "example.com",
+				"accept":            "text/html",
+				"x-forwarded-for":   "192.0.2.1",
+				"x-forwarded-proto": "https",
+			},
+		}, result{"q=a%20b", "192.0.2.1", "example.com", []string{"text/html"}, true}},
+		{"multi-value", &events.ALBTargetGroupRequest{
+			HTTPMethod:                      "GET",
+			Path:                            "/",
+			MultiValueQueryStringParameters: map[string][]string{"b": {"2"}, "a": {"1", "x%2By"}},
+			MultiValueHeaders: map[string][]string{
+				"host":              {"example.com"},
+				"accept":            {"text/html", "application/json"},
+				"x-forwarded-for":   {"198.51.100.1, 192.0.2.1"},
+				"x-forwarded-proto": {"http"},
+			},
+		}, result{"a=1&a=x%2By&b=2", "192.0.2.1", "example.com", []string{"text/html", "application/json"}, false}},
+	} {
+		var got result
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = result{r.URL.RawQuery, r.RemoteAddr, r.Host, r.Header["Accept"], r.TLS != nil}
+			w.Header().Add("Vary", "Accept")
+			w.Header().Add("Vary", "Origin")
+		})
+		out, err := ALBHandler(h)(context.Background(), tc.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: handler got %+v, want %+v", tc.name, got, tc.want)
+		}
+		if tc.req.MultiValueHeaders != nil {
+			if got := out.MultiValueHeaders["Vary"]; !reflect.DeepEqual(got, []string{"Accept", "Origin"}) || out.Headers != nil {
+				t.Errorf("%s: got Vary %q in multi-value headers, headers %v", tc.name, got, out.Headers)
+			}
+		} else if out.Headers["Vary"] != "Accept, Origin" || out.MultiValueHeaders != nil {
+			t.Errorf("%s: got Vary %q, multi-value headers %v", tc.name, out.Headers["Vary"], out.MultiValueHeaders)
+		}
+	}
+}
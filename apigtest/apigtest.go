@@ -0,0 +1,200 @@
+// Package apigtest provides utilities for testing handlers created with the
+// apig package at the Lambda invocation level, with API Gateway HTTP API
+// (payload format version 2.0) events and responses.
+//
+//	h := apig.Handler(mux)
+//	evt := apigtest.NewRequestEvent("GET", "/items?limit=10", nil,
+//		apigtest.WithHeader("Authorization", "Bearer xyz"))
+//	out, err := h(context.Background(), evt)
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	resp := apigtest.ResponseToHTTP(out)
+package apigtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+
+	"github.com/artyom/apig"
+	"github.com/artyom/apig/internal/httpevent"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// EventOption modifies the event built by NewRequestEvent or
+// EventFromRequest.
+type EventOption func(*events.APIGatewayV2HTTPRequest)
+
+// NewRequestEvent returns an event for a request with the given method,
+// target and body, the way Lambda Function URLs deliver it. Its arguments
+// are interpreted the same way httptest.NewRequest interprets them: target
+// is either a path, or an absolute URL, which host is then used as the Host
+// header. Body may be nil. Like httptest.NewRequest, it panics on invalid
+// arguments.
+func NewRequestEvent(method, target string, body io.Reader, opts ...EventOption) *events.APIGatewayV2HTTPRequest {
+	return EventFromRequest(httptest.NewRequest(method, target, body), opts...)
+}
+
+// EventFromRequest converts r to an event, the way Lambda Function URLs
+// deliver it; this can be used with requests created by httptest.NewRequest.
+// It does the reverse of the conversion done by apig.Handler: Cookie headers
+// are put into the Cookies field, other headers are lowercased and their
+// values joined by commas, and request body that is not a valid UTF-8 is
+// base64-encoded. Options are applied to the resulting event.
+//
+// EventFromRequest reads r.Body, replacing it with a reader over the same
+// content, so r can still be used afterwards.
+func EventFromRequest(r *http.Request, opts ...EventOption) *events.APIGatewayV2HTTPRequest {
+	evt := httpevent.FromRequest(r)
+	for _, opt := range opts {
+		opt(evt)
+	}
+	return evt
+}
+
+// CaptureRequest converts evt to http.Request the same way apig.Handler
+// does, and returns it, so that tests can assert on the conversion. Request
+// body is read in full and is available as r.Body. Options are applied the
+// same way as with apig.Handler; if they make the event rejected before
+// reaching the handler (such as by apig.WithMaxRequestSize), CaptureRequest
+// returns an error.
+func CaptureRequest(evt *events.APIGatewayV2HTTPRequest, opts ...apig.Option) (*http.Request, error) {
+	var r *http.Request
+	var body []byte
+	var err error
+	h := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		r = req
+		body, err = io.ReadAll(req.Body)
+	})
+	out, ierr := apig.Handler(h, opts...)(context.Background(), evt)
+	if ierr != nil {
+		return nil, ierr
+	}
+	if r == nil {
+		return nil, fmt.Errorf("apigtest: request rejected with %d status", out.StatusCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return r, nil
+}
+
+// WithHeader adds request header. Values of a header added several times
+// are joined by commas, the way API Gateway does it. Cookie header is added
+// to the event Cookies field instead.
+func WithHeader(key, value string) EventOption {
+	return func(evt *events.APIGatewayV2HTTPRequest) {
+		if strings.EqualFold(key, "Cookie") {
+			for _, c := range strings.Split(value, ";") {
+				if c = strings.TrimSpace(c); c != "" {
+					evt.Cookies = append(evt.Cookies, c)
+				}
+			}
+			return
+		}
+		if evt.Headers == nil {
+			evt.Headers = make(map[string]string)
+		}
+		key = strings.ToLower(key)
+		if v, ok := evt.Headers[key]; ok {
+			value = v + "," + value
+		}
+		evt.Headers[key] = value
+		if key == "user-agent" {
+			evt.RequestContext.HTTP.UserAgent = value
+		}
+	}
+}
+
+// WithPathParameters sets path parameters of the event, such as ones API
+// Gateway extracts from routes like "/items/{id}".
+func WithPathParameters(params map[string]string) EventOption {
+	return func(evt *events.APIGatewayV2HTTPRequest) { evt.PathParameters = params }
+}
+
+// WithRouteKey sets route key of the event, such as "GET /items/{id}".
+func WithRouteKey(key string) EventOption {
+	return func(evt *events.APIGatewayV2HTTPRequest) {
+		evt.RouteKey = key
+		evt.RequestContext.RouteKey = key
+	}
+}
+
+// WithSourceIP sets client IP address of the event.
+func WithSourceIP(ip string) EventOption {
+	return func(evt *events.APIGatewayV2HTTPRequest) { evt.RequestContext.HTTP.SourceIP = ip }
+}
+
+// WithJWTClaims makes event look like it was authorized by the JWT
+// authorizer with the given claims and scopes, see apig.JWTClaims.
+func WithJWTClaims(claims map[string]string, scopes ...string) EventOption {
+	return func(evt *events.APIGatewayV2HTTPRequest) {
+		authorizer(evt).JWT = &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{
+			Claims: claims,
+			Scopes: scopes,
+		}
+	}
+}
+
+// WithAuthorizerContext makes event look like it was authorized by the
+// Lambda authorizer which returned the given context, see
+// apig.AuthorizerContext.
+func WithAuthorizerContext(ctx map[string]any) EventOption {
+	return func(evt *events.APIGatewayV2HTTPRequest) { authorizer(evt).Lambda = ctx }
+}
+
+func authorizer(evt *events.APIGatewayV2HTTPRequest) *events.APIGatewayV2HTTPRequestContextAuthorizerDescription {
+	if evt.RequestContext.Authorizer == nil {
+		evt.RequestContext.Authorizer = new(events.APIGatewayV2HTTPRequestContextAuthorizerDescription)
+	}
+	return evt.RequestContext.Authorizer
+}
+
+// ResponseToHTTP converts Lambda response to http.Response, the way API
+// Gateway would send it to the client: base64-encoded body is decoded, and
+// Cookies are sent as Set-Cookie headers. Header values joined by commas are
+// kept as a single value. It panics if body is not a valid base64 while
+// IsBase64Encoded is set.
+func ResponseToHTTP(out *events.APIGatewayV2HTTPResponse) *http.Response {
+	header := make(http.Header, len(out.Headers)+len(out.MultiValueHeaders)+1)
+	for k, v := range out.Headers {
+		header.Add(k, v)
+	}
+	for k, vv := range out.MultiValueHeaders {
+		for _, v := range vv {
+			header.Add(k, v)
+		}
+	}
+	for _, c := range out.Cookies {
+		header.Add("Set-Cookie", c)
+	}
+	body := []byte(out.Body)
+	if out.IsBase64Encoded {
+		var err error
+		if body, err = base64.StdEncoding.DecodeString(out.Body); err != nil {
+			panic("apigtest: decoding response body: " + err.Error())
+		}
+	}
+	code := out.StatusCode
+	if code == 0 {
+		code = http.StatusOK
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(code) + " " + http.StatusText(code),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
@@ -0,0 +1,191 @@
+package apigtest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/artyom/apig"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestRoundTrip(t *testing.T) {
+	type result struct {
+		Method, Path, ID, Query, Host string
+		Accept, UserAgent, RemoteAddr string
+		RouteKey, Body                string
+		Cookies                       int
+		Claims                        map[string]string
+		Scopes                        []string
+		Authorizer                    map[string]any
+	}
+	var got result
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		b, _ := io.ReadAll(r.Body)
+		got = result{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			ID:         r.PathValue("id"),
+			Query:      r.URL.RawQuery,
+			Host:       r.Host,
+			Accept:     r.Header.Get("Accept"),
+			UserAgent:  r.UserAgent(),
+			RemoteAddr: r.RemoteAddr,
+			RouteKey:   apig.RouteKey(ctx),
+			Body:       string(b),
+			Cookies:    len(r.Cookies()),
+			Scopes:     apig.JWTScopes(ctx),
+		}
+		got.Claims, _ = apig.JWTClaims(ctx)
+		got.Authorizer, _ = apig.AuthorizerContext(ctx)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Origin")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte{0, 1, 2, 0xff})
+	})
+	for _, tc := range []struct {
+		name string
+		evt  *events.APIGatewayV2HTTPRequest
+		want result
+	}{
+		{"options", NewRequestEvent("POST", "/items/42?x=1", strings.NewReader("payload"),
+			WithHeader("Accept", "text/html"),
+			WithHeader("accept", "application/json"),
+			WithHeader("User-Agent", "test/1"),
+			WithHeader("Cookie", "s=1; t=2"),
+			WithPathParameters(map[string]string{"id": "42"}),
+			WithRouteKey("POST /items/{id}"),
+			WithSourceIP("198.51.100.7"),
+			WithJWTClaims(map[string]string{"sub": "alice"}, "write"),
+		), result{
+			Method: "POST", Path: "/items/42", ID: "42", Query: "x=1", Host: "example.com",
+			Accept: "text/html,application/json", UserAgent: "test/1", RemoteAddr: "198.51.100.7",
+			RouteKey: "POST /items/{id}", Body: "payload", Cookies: 2,
+			Claims: map[string]string{"sub": "alice"}, Scopes: []string{"write"},
+		}},
+		{"absolute URL", NewRequestEvent("GET", "https://api.example.org/", nil,
+			WithAuthorizerContext(map[string]any{"tenant": "acme"}),
+		), result{
+			Method: "GET", Path: "/", Host: "api.example.org", RemoteAddr: "192.0.2.1",
+			RouteKey: "$default", Authorizer: map[string]any{"tenant": "acme"},
+		}},
+	} {
+		got = result{}
+		out, err := apig.Handler(h)(context.Background(), tc.evt)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: handler got\n%+v\nwant\n%+v", tc.name, got, tc.want)
+		}
+		resp := ResponseToHTTP(out)
+		b, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusCreated || resp.Status != "201 Created" ||
+			string(b) != "\x00\x01\x02\xff" || resp.ContentLength != 4 {
+			t.Errorf("%s: got response %q with body %q, length %d", tc.name, resp.Status, b, resp.ContentLength)
+		}
+		if got := resp.Header.Values("Set-Cookie"); !reflect.DeepEqual(got, []string{"a=1", "b=2"}) {
+			t.Errorf("%s: got Set-Cookie %q", tc.name, got)
+		}
+		if got := resp.Header.Values("Vary"); !reflect.DeepEqual(got, []string{"Accept", "Origin"}) {
+			t.Errorf("%s: got Vary %q", tc.name, got)
+		}
+	}
+}
+
+func TestEventFromRequest(t *testing.T) {
+	for _, tc := range []struct {
+		target, wantRawPath, wantQuery string
+	}{
+		{"/items", "/items", ""},
+		{"/a%2Fb", "/a%2Fb", ""},
+		{"/a%20b?x=1&y=%2F", "/a%20b", "x=1&y=%2F"},
+	} {
+		evt := EventFromRequest(httptest.NewRequest("GET", tc.target, nil))
+		if evt.RawPath != tc.wantRawPath || evt.RequestContext.HTTP.Path != tc.wantRawPath {
+			t.Errorf("%s: got raw path %q, http path %q, want %q",
+				tc.target, evt.RawPath, evt.RequestContext.HTTP.Path, tc.wantRawPath)
+		}
+		if evt.RawQueryString != tc.wantQuery {
+			t.Errorf("%s: got query %q, want %q", tc.target, evt.RawQueryString, tc.wantQuery)
+		}
+	}
+
+	r, _ := http.NewRequest("PUT", "http://example.com/a%20b?q=1", strings.NewReader("text"))
+	evt := EventFromRequest(r, WithSourceIP("203.0.113.1"))
+	if evt.RawPath != "/a%20b" || evt.RawQueryString != "q=1" || evt.RequestContext.HTTP.Method != "PUT" ||
+		evt.Body != "text" || evt.IsBase64Encoded || evt.RequestContext.HTTP.SourceIP != "203.0.113.1" {
+		t.Errorf("got event %+v", evt)
+	}
+
+	r = httptest.NewRequest("POST", "/upload", bytes.NewReader([]byte{0xff, 0x00, 0x01}))
+	r.Header.Set("Cookie", "a=1; b=2")
+	r.Header.Set("X-Custom", "v")
+	evt = EventFromRequest(r)
+	if !evt.IsBase64Encoded || evt.Body != "/wAB" {
+		t.Errorf("got body %q, base64 %v, want base64-encoded body", evt.Body, evt.IsBase64Encoded)
+	}
+	if !reflect.DeepEqual(evt.Cookies, []string{"a=1", "b=2"}) {
+		t.Errorf("got cookies %q", evt.Cookies)
+	}
+	if evt.Headers["x-custom"] != "v" || evt.Headers["host"] != "example.com" {
+		t.Errorf("got headers %v", evt.Headers)
+	}
+	if b, _ := io.ReadAll(r.Body); !bytes.Equal(b, []byte{0xff, 0x00, 0x01}) {
+		t.Errorf("request body is not restored: %q", b)
+	}
+	got, err := CaptureRequest(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(got.Body)
+	if got.Method != "POST" || got.URL.Path != "/upload" || len(got.Cookies()) != 2 {
+		t.Errorf("round trip: got %s %s with cookies %v", got.Method, got.URL.Path, got.Cookies())
+	}
+	if !bytes.Equal(body, []byte{0xff, 0x00, 0x01}) {
+		t.Errorf("round trip: got body %q", body)
+	}
+}
+
+func TestCaptureRequest(t *testing.T) {
+	evt := NewRequestEvent("POST", "/items?id=1", bytes.NewReader([]byte{0, 1, 2, 0xff}),
+		WithHeader("Content-Type", "application/octet-stream"))
+	r, err := CaptureRequest(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Method != "POST" || r.URL.String() != "/items?id=1" ||
+		r.Header.Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("got %s %s with Content-Type %q", r.Method, r.URL, r.Header.Get("Content-Type"))
+	}
+	if body, _ := io.ReadAll(r.Body); string(body) != "\x00\x01\x02\xff" {
+		t.Errorf("got body %q", body)
+	}
+
+	evt = NewRequestEvent("POST", "/", strings.NewReader("0123456789"))
+	if _, err := CaptureRequest(evt, apig.WithMaxRequestSize(4)); err == nil {
+		t.Error("oversized request: no error")
+	}
+}
+
+func TestResponseToHTTP(t *testing.T) {
+	resp := ResponseToHTTP(&events.APIGatewayV2HTTPResponse{Headers: map[string]string{"X-A": "1, 2"}})
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-A") != "1, 2" || resp.ContentLength != 0 {
+		t.Errorf("got %d with headers %v, length %d", resp.StatusCode, resp.Header, resp.ContentLength)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("malformed base64 body did not panic")
+		}
+	}()
+	ResponseToHTTP(&events.APIGatewayV2HTTPResponse{Body: "!!!", IsBase64Encoded: true})
+}
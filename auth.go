@@ -0,0 +1,98 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// IAMIdentity returns identity of the caller authenticated with AWS_IAM
+// authorization, as used by Lambda Function URLs and HTTP API routes with
+// IAM authorization. It reports false if request was not IAM-authorized.
+func IAMIdentity(ctx context.Context) (*events.APIGatewayV2HTTPRequestContextAuthorizerIAMDescription, bool) {
+	evt := eventFromContext(ctx)
+	if evt == nil || evt.RequestContext.Authorizer == nil || evt.RequestContext.Authorizer.IAM == nil {
+		return nil, false
+	}
+	return evt.RequestContext.Authorizer.IAM, true
+}
+
+// BearerToken returns token from the "Authorization: Bearer <token>" request
+// header. Scheme name is matched case-insensitively. It reports false if
+// request has no such header, or it uses a different scheme, or the token is
+// empty.
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	if token = strings.TrimSpace(token); token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// JWTClaims returns claims of the token validated by the JWT authorizer of
+// HTTP API route, or by the Cognito user pool authorizer of REST API method.
+// It reports false if request was not authorized by such an authorizer.
+func JWTClaims(ctx context.Context) (map[string]string, bool) {
+	if evt := eventFromContext(ctx); evt != nil {
+		if a := evt.RequestContext.Authorizer; a != nil && a.JWT != nil {
+			return a.JWT.Claims, true
+		}
+		return nil, false
+	}
+	if evt := EventV1(ctx); evt != nil {
+		// REST API delivers claims as a JSON object of strings
+		claims, ok := evt.RequestContext.Authorizer["claims"].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		out := make(map[string]string, len(claims))
+		for k, v := range claims {
+			if s, ok := v.(string); ok {
+				out[k] = s
+			}
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// JWTScopes returns scopes of the token validated by the JWT authorizer of
+// HTTP API route, or nil if there are none.
+func JWTScopes(ctx context.Context) []string {
+	if evt := eventFromContext(ctx); evt != nil {
+		if a := evt.RequestContext.Authorizer; a != nil && a.JWT != nil {
+			return a.JWT.Scopes
+		}
+	}
+	return nil
+}
+
+// AuthorizerContext returns context returned by the Lambda authorizer that
+// authorized the request, for HTTP API, REST API and WebSocket API requests.
+// It reports false if request was not authorized by a Lambda authorizer.
+// For REST APIs, the map also holds the principalId the authorizer returned.
+func AuthorizerContext(ctx context.Context) (map[string]any, bool) {
+	if evt := eventFromContext(ctx); evt != nil {
+		if a := evt.RequestContext.Authorizer; a != nil && a.Lambda != nil {
+			return a.Lambda, true
+		}
+		return nil, false
+	}
+	if evt := EventV1(ctx); evt != nil {
+		if _, ok := evt.RequestContext.Authorizer["claims"]; ok || len(evt.RequestContext.Authorizer) == 0 {
+			return nil, false
+		}
+		return evt.RequestContext.Authorizer, true
+	}
+	if evt, ok := ctx.Value(wsEventKey).(*events.APIGatewayWebsocketProxyRequest); ok {
+		if m, ok := evt.RequestContext.Authorizer.(map[string]any); ok && len(m) != 0 {
+			return m, true
+		}
+	}
+	return nil, false
+}
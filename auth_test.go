@@ -0,0 +1,128 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestIAMIdentity(t *testing.T) {
+	iam := &events.APIGatewayV2HTTPRequestContextAuthorizerIAMDescription{
+		AccessKey: "AKIAEXAMPLE",
+		AccountID: "123456789012",
+		UserARN:   "arn:aws:iam::123456789012:user/alice",
+	}
+	for _, tc := range []struct {
+		name       string
+		authorizer *events.APIGatewayV2HTTPRequestContextAuthorizerDescription
+		want       *events.APIGatewayV2HTTPRequestContextAuthorizerIAMDescription
+	}{
+		{"iam", &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{IAM: iam}, iam},
+		{"jwt", &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+			JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{Claims: map[string]string{"sub": "1"}},
+		}, nil},
+		{"none", nil, nil},
+	} {
+		var got *events.APIGatewayV2HTTPRequestContextAuthorizerIAMDescription
+		var ok bool
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got, ok = IAMIdentity(r.Context()) })
+		evt := testEvent("GET", "/")
+		evt.RequestContext.Authorizer = tc.authorizer
+		invoke(t, h, evt)
+		if got != tc.want || ok != (tc.want != nil) {
+			t.Errorf("%s: got %v, %v", tc.name, got, ok)
+		}
+	}
+	if _, ok := IAMIdentity(context.Background()); ok {
+		t.Error("outside of request: got ok")
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{"Bearer abc.def", "abc.def", true},
+		{"bearer abc.def", "abc.def", true},
+		{"BEARER  abc.def ", "abc.def", true},
+		{"", "", false},
+		{"Basic dXNlcjpwYXNz", "", false},
+		{"Bearer", "", false},
+		{"Bearer ", "", false},
+	} {
+		r := &http.Request{Header: make(http.Header)}
+		if tc.header != "" {
+			r.Header.Set("Authorization", tc.header)
+		}
+		got, ok := BearerToken(r)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%q: got %q, %v, want %q, %v", tc.header, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestAuthorizerAccessors(t *testing.T) {
+	type result struct {
+		Claims   map[string]string
+		ClaimsOK bool
+		Scopes   []string
+		Lambda   map[string]any
+		LambdaOK bool
+	}
+	var got result
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		got = result{}
+		got.Claims, got.ClaimsOK = JWTClaims(ctx)
+		got.Scopes = JWTScopes(ctx)
+		got.Lambda, got.LambdaOK = AuthorizerContext(ctx)
+	})
+	for _, tc := range []struct {
+		name       string
+		authorizer *events.APIGatewayV2HTTPRequestContextAuthorizerDescription
+		want       result
+	}{
+		{"none", nil, result{}},
+		{"jwt", &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+			JWT: &events.APIGatewayV2HTTPRequestContextAuthorizerJWTDescription{
+				Claims: map[string]string{"sub": "alice"},
+				Scopes: []string{"read"},
+			},
+		}, result{Claims: map[string]string{"sub": "alice"}, ClaimsOK: true, Scopes: []string{"read"}}},
+		{"lambda", &events.APIGatewayV2HTTPRequestContextAuthorizerDescription{
+			Lambda: map[string]any{"tenant": "acme"},
+		}, result{Lambda: map[string]any{"tenant": "acme"}, LambdaOK: true}},
+	} {
+		evt := testEvent("GET", "/")
+		evt.RequestContext.Authorizer = tc.authorizer
+		invoke(t, h, evt)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name       string
+		authorizer map[string]any
+		want       result
+	}{
+		{"rest cognito", map[string]any{"claims": map[string]any{"sub": "bob", "n": 1.0}},
+			result{Claims: map[string]string{"sub": "bob"}, ClaimsOK: true}},
+		{"rest lambda", map[string]any{"principalId": "bob", "tenant": "acme"},
+			result{Lambda: map[string]any{"principalId": "bob", "tenant": "acme"}, LambdaOK: true}},
+		{"rest none", nil, result{}},
+	} {
+		req := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/"}
+		req.RequestContext.Authorizer = tc.authorizer
+		HandlerV1(h)(ctx, req)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
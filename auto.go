@@ -0,0 +1,91 @@
+package apig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// AutoHandler returns function suitable to use as an AWS Lambda handler with
+// github.com/aws/aws-lambda-go/lambda package that serves requests of API
+// Gateway HTTP APIs and Lambda Function URLs (payload format 2.0), API
+// Gateway REST APIs and HTTP APIs using payload format 1.0, and Application
+// Load Balancers, detecting kind of each event by its shape. This allows the
+// same function to be deployed behind any of them. Requests are served the
+// same way as by Handler, HandlerV1 and ALBHandler respectively.
+//
+// AutoHandler works with raw event payloads, so WithJSONCodec,
+// WithOmitEmptyBody and WithResponseFormat apply to it, except for ALB
+// responses, which always use the ALB format. Events of other kinds are
+// rejected with an error.
+func AutoHandler(h http.Handler, opts ...Option) func(context.Context, json.RawMessage) (json.RawMessage, error) {
+	if h == nil {
+		panic("AutoHandler called with nil argument")
+	}
+	return newHandler(h, opts).runAuto
+}
+
+// errUnknownEvent is returned by AutoHandler for events it cannot serve.
+var errUnknownEvent = errors.New("apig: unsupported event format")
+
+// eventShape holds fields telling kinds of events apart.
+type eventShape struct {
+	Version        string `json:"version"`
+	HTTPMethod     string `json:"httpMethod"`
+	RequestContext struct {
+		ELB          json.RawMessage `json:"elb"`
+		HTTP         json.RawMessage `json:"http"`
+		ConnectionID string          `json:"connectionId"`
+	} `json:"requestContext"`
+}
+
+func (h *lambdaHandler) runAuto(ctx context.Context, payload json.RawMessage) (json.RawMessage, error) {
+	var shape eventShape
+	if err := h.unmarshal(payload, &shape); err != nil {
+		return nil, err
+	}
+	switch {
+	case shape.RequestContext.ELB != nil:
+		req := new(events.ALBTargetGroupRequest)
+		if err := h.unmarshal(payload, req); err != nil {
+			return nil, err
+		}
+		out, err := h.runALB(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return h.marshal(out)
+	case shape.Version == "2.0" || shape.RequestContext.HTTP != nil:
+		req := new(events.APIGatewayV2HTTPRequest)
+		if err := h.unmarshal(payload, req); err != nil {
+			return nil, err
+		}
+		atomic.AddUint64(&invocations, 1)
+		r, res, err := h.newRequest(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			res = h.serve(r)
+		}
+		defer res.release()
+		return h.encodeResponse(ctx, res, V2)
+	case shape.HTTPMethod != "" && shape.RequestContext.ConnectionID == "":
+		req := new(events.APIGatewayProxyRequest)
+		if err := h.unmarshal(payload, req); err != nil {
+			return nil, err
+		}
+		atomic.AddUint64(&invocations, 1)
+		r, res := h.newRequestV1(ctx, req)
+		if res == nil {
+			res = h.serve(r)
+		}
+		defer res.release()
+		return h.encodeResponse(ctx, res, V1)
+	}
+	return nil, errUnknownEvent
+}
@@ -0,0 +1,62 @@
+package apig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestAutoHandler(t *testing.T) {
+	var got RequestSource
+	var path string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, path = Source(r.Context()), r.URL.Path
+	})
+	for _, tc := range []struct {
+		name    string
+		payload string
+		want    RequestSource
+	}{
+		{"function url", `{"version":"2.0","rawPath":"/a","requestContext":{"domainName":"x.lambda-url.us-east-1.on.aws",` +
+			`"http":{"method":"GET","path":"/a"}}}`, SourceFunctionURL},
+		{"http api", `{"version":"2.0","rawPath":"/a","requestContext":{"apiId":"abc","stage":"$default",` +
+			`"http":{"method":"GET","path":"/a"}}}`, SourceHTTPAPI},
+		{"rest api", `{"resource":"/a","path":"/a","httpMethod":"GET","requestContext":{"stage":"prod"}}`, SourceRESTAPI},
+		{"http api 1.0", `{"version":"1.0","path":"/a","httpMethod":"GET","requestContext":{"apiId":"abc"}}`, SourceRESTAPI},
+		{"alb", `{"httpMethod":"GET","path":"/a","headers":{"host":"example.com"},` +
+			`"requestContext":{"elb":{"targetGroupArn":"arn"}}}`, SourceALB},
+	} {
+		got, path = -1, ""
+		b, err := AutoHandler(h)(context.Background(), json.RawMessage(tc.payload))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want || path != "/a" {
+			t.Errorf("%s: got source %v, path %q, want %v", tc.name, got, path, tc.want)
+		}
+		var out struct {
+			StatusCode        int    `json:"statusCode"`
+			StatusDescription string `json:"statusDescription"`
+		}
+		if err := json.Unmarshal(b, &out); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if out.StatusCode != http.StatusOK || (out.StatusDescription != "") != (tc.want == SourceALB) {
+			t.Errorf("%s: got response %s", tc.name, b)
+		}
+	}
+
+	for _, payload := range []string{
+		`{"httpMethod":"GET","path":"/","requestContext":{"connectionId":"abc="}}`,
+		`{"Records":[]}`,
+	} {
+		if _, err := AutoHandler(h)(context.Background(), json.RawMessage(payload)); !errors.Is(err, errUnknownEvent) {
+			t.Errorf("%s: got error %v, want %v", payload, err, errUnknownEvent)
+		}
+	}
+	if _, err := AutoHandler(h)(context.Background(), json.RawMessage(`[`)); err == nil {
+		t.Error("malformed payload: no error")
+	}
+}
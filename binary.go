@@ -0,0 +1,67 @@
+package apig
+
+// WithBinaryContentTypes configures additional content types of responses
+// that are always base64-encoded, regardless of whether body is a valid
+// UTF-8. Each type is either a media type ("application/x-protobuf"), a type
+// with any subtype ("model/*"), or a suffix starting with "*"
+// ("*+octet-stream"). Images, audio, video, fonts and common binary
+// application types, including gRPC-Web ones, are always treated as binary.
+// See WithBase64Mode to base64-encode responses based on their type only.
+//
+// Serving gRPC-Web with API Gateway REST API also requires adding
+// application/grpc-web+proto (or "*/*") to its binary media types, so that
+// request bodies are delivered base64-encoded; Function URLs and HTTP APIs
+// do this automatically.
+func WithBinaryContentTypes(types ...string) Option {
+	return func(c *config) { c.binaryTypes = append(c.binaryTypes, types...) }
+}
+
+// WithBinaryContentTypeFunc configures a function reporting whether response
+// with the given Content-Type header value has to be base64-encoded. It is
+// consulted in addition to the types configured with WithBinaryContentTypes,
+// and can be used for types not expressible with simple patterns. Function
+// is not called for responses without Content-Type.
+func WithBinaryContentTypeFunc(fn func(contentType string) bool) Option {
+	return func(c *config) { c.binaryTypeFunc = fn }
+}
+
+// binaryResponse reports whether response with content type ct has to be
+// base64-encoded because of its type.
+func (c *config) binaryResponse(ct string) bool {
+	if ct == "" {
+		return false
+	}
+	if isBinaryContentType(ct) || matchContentType(c.binaryTypes, ct) {
+		return true
+	}
+	return c.binaryTypeFunc != nil && c.binaryTypeFunc(ct)
+}
+
+// Base64Mode controls which response bodies are base64-encoded, see
+// WithBase64Mode.
+type Base64Mode int
+
+const (
+	// Base64Auto encodes bodies that have binary content type (see
+	// WithBinaryContentTypes), a Content-Encoding, or are not a valid UTF-8.
+	// This is the default.
+	Base64Auto Base64Mode = iota
+	// Base64ByContentType encodes bodies that have binary content type or a
+	// Content-Encoding, regardless of their content, the way API Gateway
+	// binary media types work. Bodies of other types that are not a valid
+	// UTF-8 get invalid bytes replaced when Lambda response is encoded to
+	// JSON.
+	Base64ByContentType
+	// Base64Always encodes all non-empty bodies.
+	Base64Always
+	// Base64Never never encodes bodies, like Base64ByContentType that has no
+	// binary types.
+	Base64Never
+)
+
+// WithBase64Mode configures which response bodies are base64-encoded in the
+// Lambda response. Handler can still override the decision for a particular
+// response with Base64Header. Empty bodies are never base64-encoded.
+func WithBase64Mode(mode Base64Mode) Option {
+	return func(c *config) { c.base64Mode = mode }
+}
@@ -0,0 +1,124 @@
+package apig
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBinaryContentTypeFunc(t *testing.T) {
+	vendor := func(ct string) bool {
+		return strings.HasPrefix(ct, "application/vnd.") && strings.Contains(ct, "+octet-stream")
+	}
+	for _, tc := range []struct {
+		contentType string
+		opts        []Option
+		want        bool
+	}{
+		{"application/vnd.acme.v2+octet-stream", nil, false},
+		{"application/vnd.acme.v2+octet-stream", []Option{WithBinaryContentTypeFunc(vendor)}, true},
+		{"application/vnd.acme.v2+octet-stream; q=1", []Option{WithBinaryContentTypeFunc(vendor)}, true},
+		{"application/vnd.acme+json", []Option{WithBinaryContentTypeFunc(vendor)}, false},
+		{"application/x-protobuf", []Option{WithBinaryContentTypes("application/x-protobuf"), WithBinaryContentTypeFunc(vendor)}, true},
+		{"image/png", []Option{WithBinaryContentTypeFunc(vendor)}, true},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tc.contentType)
+			io.WriteString(w, "text")
+		})
+		out := invoke(t, h, testEvent("GET", "/"), tc.opts...)
+		if out.IsBase64Encoded != tc.want {
+			t.Errorf("%q with %d options: got base64 %v, want %v", tc.contentType, len(tc.opts), out.IsBase64Encoded, tc.want)
+		}
+	}
+}
+
+func TestGRPCWebRoundTrip(t *testing.T) {
+	// length-prefixed frames: a data frame with a protobuf message that is
+	// also valid UTF-8, and a trailers frame
+	frame := []byte("\x00\x00\x00\x00\x05\x0a\x03abc")
+	frame = append(frame, "\x80\x00\x00\x00\x0fgrpc-status:0\r\n"...)
+	for _, ct := range []string{"application/grpc-web", "application/grpc-web+proto", "application/grpc"} {
+		var got []byte
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, _ = io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+			w.Write(got)
+		})
+		evt := testEvent("POST", "/pkg.Service/Method")
+		evt.Headers["content-type"] = ct
+		evt.Body = base64.StdEncoding.EncodeToString(frame)
+		evt.IsBase64Encoded = true
+		out := invoke(t, h, evt)
+		if !bytes.Equal(got, frame) {
+			t.Errorf("%s: handler got body %q, want %q", ct, got, frame)
+		}
+		if !out.IsBase64Encoded || out.Body != evt.Body {
+			t.Errorf("%s: got response body %q, base64 %v", ct, out.Body, out.IsBase64Encoded)
+		}
+	}
+	if isBinaryContentType("application/grpc-web-text") {
+		t.Error("application/grpc-web-text is treated as binary")
+	}
+}
+
+func TestBase64Mode(t *testing.T) {
+	type body struct{ contentType, encoding, data string }
+	var (
+		text    = body{"text/plain", "", "hello"}
+		invalid = body{"text/plain", "", "\xff\xfe"}
+		image   = body{"image/png", "", "PNG-like text"}
+		gzipped = body{"application/json", "gzip", "compressed"}
+		empty   = body{"image/png", "", ""}
+	)
+	for _, tc := range []struct {
+		mode Base64Mode
+		body body
+		want bool
+	}{
+		{Base64Auto, text, false},
+		{Base64Auto, invalid, true},
+		{Base64Auto, image, true},
+		{Base64Auto, gzipped, true},
+		{Base64Auto, empty, false},
+		{Base64ByContentType, text, false},
+		{Base64ByContentType, invalid, false},
+		{Base64ByContentType, image, true},
+		{Base64ByContentType, gzipped, true},
+		{Base64Always, text, true},
+		{Base64Always, empty, false},
+		{Base64Never, invalid, false},
+		{Base64Never, image, false},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tc.body.contentType)
+			if tc.body.encoding != "" {
+				w.Header().Set("Content-Encoding", tc.body.encoding)
+			}
+			io.WriteString(w, tc.body.data)
+		})
+		out := invoke(t, h, testEvent("GET", "/"), WithBase64Mode(tc.mode))
+		if out.IsBase64Encoded != tc.want {
+			t.Errorf("mode %d, %+v: got base64 %v, want %v", tc.mode, tc.body, out.IsBase64Encoded, tc.want)
+		}
+		want := tc.body.data
+		if tc.want {
+			want = base64.StdEncoding.EncodeToString([]byte(want))
+		}
+		if out.Body != want {
+			t.Errorf("mode %d, %+v: got body %q, want %q", tc.mode, tc.body, out.Body, want)
+		}
+	}
+
+	// handler decision wins over the mode
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(Base64Header, "false")
+		io.WriteString(w, "hello")
+	})
+	if out := invoke(t, h, testEvent("GET", "/"), WithBase64Mode(Base64Always)); out.IsBase64Encoded {
+		t.Errorf("Base64Header: got body %q encoded", out.Body)
+	}
+}
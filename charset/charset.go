@@ -0,0 +1,72 @@
+// Package charset provides an apig option to transcode request bodies to
+// UTF-8. It is a separate package, so that programs not using it do not
+// depend on golang.org/x/text.
+package charset
+
+import (
+	"context"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/artyom/apig"
+	"github.com/aws/aws-lambda-go/events"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// WithTranscoding configures handler to transcode bodies of requests with
+// content types that declare a charset other than UTF-8, such as
+// "text/plain; charset=iso-8859-1", to UTF-8. Content-Type header of such
+// requests is updated to declare UTF-8 charset. Requests with unknown
+// charsets are passed as is; requests with bodies that cannot be decoded are
+// answered with 400 Bad Request.
+//
+// The option is implemented with apig.WithRequestInterceptor, so it applies
+// to API Gateway HTTP API and Lambda Function URL events.
+func WithTranscoding() apig.Option {
+	return apig.WithRequestInterceptor(transcode)
+}
+
+// transcode converts body of event req to UTF-8 if its content type declares
+// another charset.
+func transcode(_ context.Context, req *events.APIGatewayV2HTTPRequest) error {
+	var key, ct string
+	for k, v := range req.Headers {
+		if strings.EqualFold(k, "Content-Type") {
+			key, ct = k, v
+			break
+		}
+	}
+	if ct == "" {
+		return nil
+	}
+	mt, params, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return nil
+	}
+	charset := strings.ToLower(params["charset"])
+	switch charset {
+	case "", "utf-8", "utf8", "us-ascii":
+		return nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil
+	}
+	body := req.Body
+	if req.IsBase64Encoded {
+		b, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return &apig.HTTPError{Code: http.StatusBadRequest}
+		}
+		body = string(b)
+	}
+	if body, err = enc.NewDecoder().String(body); err != nil {
+		return &apig.HTTPError{Code: http.StatusBadRequest}
+	}
+	req.Body, req.IsBase64Encoded = body, false
+	params["charset"] = "utf-8"
+	req.Headers[key] = mime.FormatMediaType(mt, params)
+	return nil
+}
@@ -0,0 +1,50 @@
+package charset
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/artyom/apig"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWithTranscoding(t *testing.T) {
+	for _, tc := range []struct {
+		name, contentType, body string
+		isBase64                bool
+		wantCode                int
+		wantType, wantBody      string
+	}{
+		{"latin-1", "text/plain; charset=iso-8859-1", "caf\xe9", false, http.StatusOK, "text/plain; charset=utf-8", "café"},
+		{"latin-1 base64", "text/plain; charset=iso-8859-1", base64.StdEncoding.EncodeToString([]byte("caf\xe9")), true, http.StatusOK, "text/plain; charset=utf-8", "café"},
+		{"windows-1252", "text/html; charset=windows-1252", "\x93hi\x94", false, http.StatusOK, "text/html; charset=utf-8", "“hi”"},
+		{"utf-8", "text/plain; charset=utf-8", "café", false, http.StatusOK, "text/plain; charset=utf-8", "café"},
+		{"no charset", "application/octet-stream", "caf\xe9", false, http.StatusOK, "application/octet-stream", "caf\xe9"},
+		{"unknown charset", "text/plain; charset=x-unknown", "caf\xe9", false, http.StatusOK, "text/plain; charset=x-unknown", "caf\xe9"},
+		{"malformed base64", "text/plain; charset=iso-8859-1", "!!!", true, http.StatusBadRequest, "", ""},
+	} {
+		var gotType, gotBody string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			gotType, gotBody = r.Header.Get("Content-Type"), string(b)
+		})
+		evt := &events.APIGatewayV2HTTPRequest{
+			RawPath:         "/",
+			Headers:         map[string]string{"content-type": tc.contentType},
+			Body:            tc.body,
+			IsBase64Encoded: tc.isBase64,
+		}
+		evt.RequestContext.HTTP.Method = "POST"
+		out, err := apig.Handler(h, WithTranscoding())(context.Background(), evt)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if out.StatusCode != tc.wantCode || gotType != tc.wantType || gotBody != tc.wantBody {
+			t.Errorf("%s: got %d, %q with body %q, want %d, %q with %q",
+				tc.name, out.StatusCode, gotType, gotBody, tc.wantCode, tc.wantType, tc.wantBody)
+		}
+	}
+}
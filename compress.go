@@ -0,0 +1,138 @@
+package apig
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultCompressibleTypes lists content types compressed by default when
+// compression is enabled with WithCompression.
+var defaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"*+json",
+	"*+xml",
+}
+
+// WithCompression enables gzip compression of responses with bodies of at
+// least minSize bytes, if the client accepts gzip encoding (see WithEncoder
+// for other encodings). Only responses of compressible content types are
+// compressed, see WithCompressibleTypes. Responses that already have
+// Content-Encoding set are left intact. Compressed responses are always
+// base64-encoded.
+//
+// With StreamingHandler, responses are compressed as they are streamed, and
+// minSize is not taken into account.
+func WithCompression(minSize int) Option {
+	return func(c *config) {
+		c.compress = true
+		c.compressMinSize = minSize
+	}
+}
+
+// WithCompressibleTypes overrides the list of content types compressed when
+// compression is enabled with WithCompression. Each type is either a media
+// type ("application/json"), a type with any subtype ("text/*"), or a suffix
+// starting with "*" ("*+json"). By default text types, JSON, JavaScript and
+// XML are compressed, and already compressed media, like images, is not.
+func WithCompressibleTypes(types ...string) Option {
+	return func(c *config) { c.compressTypes = types }
+}
+
+// WithEncoder registers an additional content coding for compression
+// enabled with WithCompression, such as brotli, which is not implemented by
+// the standard library:
+//
+//	apig.WithEncoder("br", func(w io.Writer) io.WriteCloser {
+//		return brotli.NewWriter(w)
+//	})
+//
+// If client accepts several registered codings, the first registered one is
+// used; gzip is used if client accepts none of them. Registered encoders
+// only apply to buffered responses, StreamingHandler only uses gzip.
+func WithEncoder(coding string, fn func(io.Writer) io.WriteCloser) Option {
+	return func(c *config) { c.encoders = append(c.encoders, encoder{coding: coding, fn: fn}) }
+}
+
+type encoder struct {
+	coding string
+	fn     func(io.Writer) io.WriteCloser
+}
+
+// compressResponse compresses body of res if request r allows it and
+// response is suitable for compression.
+func (h *lambdaHandler) compressResponse(r *http.Request, res *response) {
+	if len(res.body) < h.compressMinSize || len(res.body) == 0 ||
+		res.status == http.StatusNoContent || res.status == http.StatusNotModified ||
+		res.status == http.StatusPartialContent ||
+		res.header.Get("Content-Encoding") != "" {
+		return
+	}
+	coding := "gzip"
+	newWriter := func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }
+	for _, e := range h.encoders {
+		if acceptsEncoding(r.Header, e.coding) {
+			coding, newWriter = e.coding, e.fn
+			break
+		}
+	}
+	if coding == "gzip" && !acceptsEncoding(r.Header, "gzip") {
+		return
+	}
+	types := h.compressTypes
+	if types == nil {
+		types = defaultCompressibleTypes
+	}
+	if !matchContentType(types, res.header.Get("Content-Type")) {
+		return
+	}
+	var buf bytes.Buffer
+	zw := newWriter(&buf)
+	if _, err := zw.Write(res.body); err != nil {
+		return
+	}
+	if err := zw.Close(); err != nil {
+		return
+	}
+	res.body = buf.Bytes()
+	res.header.Set("Content-Encoding", coding)
+	res.header.Add("Vary", "Accept-Encoding")
+	if res.header.Get("Content-Length") != "" {
+		res.header.Set("Content-Length", strconv.Itoa(len(res.body)))
+	}
+	t := true
+	res.base64 = &t
+}
+
+// acceptsEncoding reports whether Accept-Encoding request headers allow the
+// given content coding.
+func acceptsEncoding(header http.Header, coding string) bool {
+	var wildcard bool
+	for _, v := range header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(v, ",") {
+			name, params, _ := strings.Cut(part, ";")
+			name = strings.TrimSpace(name)
+			if !strings.EqualFold(name, coding) && name != "*" {
+				continue
+			}
+			ok := true
+			if _, q, found := strings.Cut(params, "q="); found {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(q), 64); err == nil && f == 0 {
+					ok = false
+				}
+			}
+			if name != "*" {
+				return ok
+			}
+			wildcard = ok
+		}
+	}
+	return wildcard
+}
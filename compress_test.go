@@ -0,0 +1,121 @@
+package apig
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCompressibleTypes(t *testing.T) {
+	large := strings.Repeat(`{"k":"v"}`, 200)
+	for _, tc := range []struct {
+		name, contentType, body string
+		opts                    []Option
+		wantGzip                bool
+	}{
+		{"json above threshold", "application/json", large, nil, true},
+		{"json below threshold", "application/json", `{"k":"v"}`, nil, false},
+		{"text with charset", "text/html; charset=utf-8", large, nil, true},
+		{"suffix type", "application/problem+json", large, nil, true},
+		{"image", "image/jpeg", large, nil, false},
+		{"custom list includes", "application/x-custom", large, []Option{WithCompressibleTypes("application/x-custom")}, true},
+		{"custom list excludes", "application/json", large, []Option{WithCompressibleTypes("text/*")}, false},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tc.contentType)
+			io.WriteString(w, tc.body)
+		})
+		evt := testEvent("GET", "/")
+		evt.Headers["accept-encoding"] = "gzip, deflate"
+		out := invoke(t, h, evt, append([]Option{WithCompression(1024)}, tc.opts...)...)
+		if gotGzip := out.Headers["Content-Encoding"] == "gzip"; gotGzip != tc.wantGzip {
+			t.Errorf("%s: got Content-Encoding %q, want gzip: %v", tc.name, out.Headers["Content-Encoding"], tc.wantGzip)
+			continue
+		}
+		body := out.Body
+		if out.IsBase64Encoded {
+			b, err := base64.StdEncoding.DecodeString(out.Body)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			body = string(b)
+		}
+		if tc.wantGzip {
+			zr, err := gzip.NewReader(strings.NewReader(body))
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			b, err := io.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			body = string(b)
+		}
+		if body != tc.body {
+			t.Errorf("%s: body does not match after decompression", tc.name)
+		}
+	}
+}
+
+// upperWriter is a fake content coding upper-casing the data.
+type upperWriter struct{ w io.Writer }
+
+func (u upperWriter) Write(p []byte) (int, error) { return u.w.Write(bytes.ToUpper(p)) }
+func (u upperWriter) Close() error                { return nil }
+
+func TestEncoders(t *testing.T) {
+	body := strings.Repeat("hello, world ", 100)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, body)
+	})
+	opts := []Option{
+		WithCompression(0),
+		WithEncoder("br", func(w io.Writer) io.WriteCloser { return upperWriter{w} }),
+		WithEncoder("deflate", func(w io.Writer) io.WriteCloser {
+			zw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return zw
+		}),
+	}
+	for _, tc := range []struct {
+		accept, want string
+	}{
+		{"deflate, br", "br"},
+		{"gzip, deflate", "deflate"},
+		{"gzip, br;q=0", "gzip"},
+		{"identity", ""},
+	} {
+		evt := testEvent("GET", "/")
+		evt.Headers["accept-encoding"] = tc.accept
+		out := invoke(t, h, evt, opts...)
+		if got := out.Headers["Content-Encoding"]; got != tc.want {
+			t.Errorf("%q: got Content-Encoding %q, want %q", tc.accept, got, tc.want)
+			continue
+		}
+		var r io.Reader = strings.NewReader(out.Body)
+		if out.IsBase64Encoded {
+			r = base64.NewDecoder(base64.StdEncoding, r)
+		}
+		want := body
+		switch tc.want {
+		case "br":
+			want = strings.ToUpper(body)
+		case "deflate":
+			r = flate.NewReader(r)
+		case "gzip":
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				t.Fatalf("%q: %v", tc.accept, err)
+			}
+			r = zr
+		}
+		if b, err := io.ReadAll(r); err != nil || string(b) != want {
+			t.Errorf("%q: got decoded body %.20q..., error %v", tc.accept, b, err)
+		}
+	}
+}
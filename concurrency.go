@@ -0,0 +1,38 @@
+package apig
+
+import "net/http"
+
+// WithMaxConcurrency limits the number of requests handler serves
+// concurrently to n. Requests exceeding the limit are not queued, but get 503
+// Service Unavailable response with a Retry-After header. Zero or negative n
+// means no limit, which is the default.
+//
+// A Lambda execution environment normally processes one invocation at a
+// time, so this option is only useful if the same handler is invoked
+// concurrently in a single process.
+func WithMaxConcurrency(n int) Option {
+	return func(c *config) { c.maxConcurrency = n }
+}
+
+// acquire takes a slot for serving a request, returning response that should
+// be sent instead if no slot is available. On success caller must call
+// release once the request is served.
+func (h *lambdaHandler) acquire() *response {
+	if h.sem == nil {
+		return nil
+	}
+	select {
+	case h.sem <- struct{}{}:
+		return nil
+	default:
+		res := errorResponse(http.StatusServiceUnavailable)
+		res.header.Set("Retry-After", "1")
+		return res
+	}
+}
+
+func (h *lambdaHandler) release() {
+	if h.sem != nil {
+		<-h.sem
+	}
+}
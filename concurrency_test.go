@@ -0,0 +1,61 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestMaxConcurrency(t *testing.T) {
+	const n = 3
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-unblock
+	})
+	fn := Handler(h, WithMaxConcurrency(n))
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := fn(context.Background(), testEvent("GET", "/"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			codes <- out.StatusCode
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-started
+	}
+	// all slots are taken by blocked handlers
+	out, err := fn(context.Background(), testEvent("GET", "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.StatusCode != http.StatusServiceUnavailable || out.Headers["Retry-After"] == "" {
+		t.Errorf("request over limit: got %d with Retry-After %q, want 503 with Retry-After",
+			out.StatusCode, out.Headers["Retry-After"])
+	}
+	close(unblock)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("request within limit: got status %d, want 200", code)
+		}
+	}
+	// slots are released once requests are served
+	go func() { <-started }()
+	if out, err = fn(context.Background(), testEvent("GET", "/")); err != nil {
+		t.Fatal(err)
+	}
+	if out.StatusCode != http.StatusOK {
+		t.Errorf("request after others finished: got status %d, want 200", out.StatusCode)
+	}
+}
@@ -0,0 +1,74 @@
+package apig
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WithConditionalRequests enables handling of conditional GET and HEAD
+// requests for handlers that do not implement it themselves. If handler
+// responds with 200 OK and sets an ETag or Last-Modified header, and request
+// If-None-Match or If-Modified-Since header says the client already has this
+// version, the response is replaced with 304 Not Modified with an empty body,
+// keeping the validators and other headers. Rules follow those of
+// http.ServeContent: If-None-Match takes precedence over If-Modified-Since.
+//
+// Conditional requests are only handled for buffered responses.
+func WithConditionalRequests() Option {
+	return func(c *config) { c.conditional = true }
+}
+
+// checkNotModified turns res into 304 Not Modified response if conditional
+// request r allows it.
+func checkNotModified(r *http.Request, res *response) {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || res.status != http.StatusOK {
+		return
+	}
+	if !notModified(r.Header, res.header) {
+		return
+	}
+	res.status = http.StatusNotModified
+	res.body = nil
+	res.base64 = nil
+	res.header.Del("Content-Type")
+	res.header.Del("Content-Length")
+}
+
+// notModified reports whether request headers reqh match response
+// validators in resh, so that 304 Not Modified response can be sent.
+func notModified(reqh, resh http.Header) bool {
+	if inm := reqh.Get("If-None-Match"); inm != "" {
+		etag := resh.Get("ETag")
+		if etag == "" {
+			return false
+		}
+		for _, v := range strings.Split(inm, ",") {
+			v = strings.TrimSpace(v)
+			if v == "*" || weakMatch(v, etag) {
+				return true
+			}
+		}
+		return false
+	}
+	ims := reqh.Get("If-Modified-Since")
+	lm := resh.Get("Last-Modified")
+	if ims == "" || lm == "" {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modtime, err := http.ParseTime(lm)
+	if err != nil {
+		return false
+	}
+	return !modtime.Truncate(time.Second).After(t)
+}
+
+// weakMatch reports whether two entity tags match using the weak comparison
+// function of RFC 9110.
+func weakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
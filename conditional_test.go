@@ -0,0 +1,47 @@
+package apig
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestConditionalRequests(t *testing.T) {
+	const lastModified = "Wed, 21 Oct 2015 07:28:00 GMT"
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "content")
+	})
+	for _, tc := range []struct {
+		name, method string
+		headers      map[string]string
+		wantCode     int
+	}{
+		{"unconditional", "GET", nil, http.StatusOK},
+		{"etag match", "GET", map[string]string{"if-none-match": `"v0", "v1"`}, http.StatusNotModified},
+		{"weak etag match", "GET", map[string]string{"if-none-match": `W/"v1"`}, http.StatusNotModified},
+		{"wildcard", "HEAD", map[string]string{"if-none-match": "*"}, http.StatusNotModified},
+		{"etag mismatch", "GET", map[string]string{"if-none-match": `"v2"`}, http.StatusOK},
+		{"etag takes precedence", "GET", map[string]string{"if-none-match": `"v2"`, "if-modified-since": lastModified}, http.StatusOK},
+		{"not modified since", "GET", map[string]string{"if-modified-since": lastModified}, http.StatusNotModified},
+		{"modified since", "GET", map[string]string{"if-modified-since": "Tue, 20 Oct 2015 07:28:00 GMT"}, http.StatusOK},
+		{"post", "POST", map[string]string{"if-none-match": `"v1"`}, http.StatusOK},
+	} {
+		evt := testEvent(tc.method, "/")
+		for k, v := range tc.headers {
+			evt.Headers[k] = v
+		}
+		out := invoke(t, h, evt, WithConditionalRequests())
+		if out.StatusCode != tc.wantCode {
+			t.Errorf("%s: got status %d, want %d", tc.name, out.StatusCode, tc.wantCode)
+			continue
+		}
+		if tc.wantCode == http.StatusNotModified {
+			if out.Body != "" || out.Headers["Etag"] != `"v1"` || out.Headers["Last-Modified"] != lastModified {
+				t.Errorf("%s: got body %q, headers %v", tc.name, out.Body, out.Headers)
+			}
+		}
+	}
+}
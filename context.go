@@ -0,0 +1,369 @@
+package apig
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+type ctxKey int
+
+const (
+	eventKey ctxKey = iota
+	albEventKey
+	wsEventKey
+	managementAPIKey
+	panicKey
+	configKey
+	requestIDKey
+	valuesKey
+	v1EventKey
+	traceKey
+	edgeEventKey
+	edgeOriginKey
+)
+
+// eventFromContext returns API Gateway event stored in ctx, or nil.
+func eventFromContext(ctx context.Context) *events.APIGatewayV2HTTPRequest {
+	evt, _ := ctx.Value(eventKey).(*events.APIGatewayV2HTTPRequest)
+	return evt
+}
+
+// Event returns API Gateway HTTP API (or Lambda Function URL) event the
+// request was created from by Handler, StreamingHandler, NewHandler or
+// AutoHandler, or nil if ctx belongs to a request of some other kind. Event
+// is shared by all users of the request, and must not be modified.
+func Event(ctx context.Context) *events.APIGatewayV2HTTPRequest {
+	return eventFromContext(ctx)
+}
+
+// RequestContext returns request context of the event returned by Event, or
+// nil if there is no such event. It must not be modified.
+func RequestContext(ctx context.Context) *events.APIGatewayV2HTTPRequestContext {
+	if evt := eventFromContext(ctx); evt != nil {
+		return &evt.RequestContext
+	}
+	return nil
+}
+
+// EventV1 returns API Gateway REST API event the request was created from
+// by HandlerV1 or AutoHandler, or nil if ctx belongs to a request of some
+// other kind. It must not be modified.
+func EventV1(ctx context.Context) *events.APIGatewayProxyRequest {
+	evt, _ := ctx.Value(v1EventKey).(*events.APIGatewayProxyRequest)
+	return evt
+}
+
+// RawQueryString returns the query string of the request exactly as it was
+// delivered in the API Gateway event, or an empty string if ctx does not
+// belong to a request created by Handler. It is the same value as
+// r.URL.RawQuery, and can be used by handlers that need to parse
+// non-standard query encodings.
+func RawQueryString(ctx context.Context) string {
+	if evt := eventFromContext(ctx); evt != nil {
+		return evt.RawQueryString
+	}
+	return ""
+}
+
+// RequestTime returns time API Gateway received the request, as reported in
+// the event request context. It returns zero time if it is not known.
+func RequestTime(ctx context.Context) time.Time {
+	var ms int64
+	if evt := eventFromContext(ctx); evt != nil {
+		ms = evt.RequestContext.TimeEpoch
+	} else if evt, ok := ctx.Value(v1EventKey).(*events.APIGatewayProxyRequest); ok {
+		ms = evt.RequestContext.RequestTimeEpoch
+	} else if evt, ok := ctx.Value(wsEventKey).(*events.APIGatewayWebsocketProxyRequest); ok {
+		ms = evt.RequestContext.RequestTimeEpoch
+	}
+	if ms == 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
+
+// GatewayRequestID returns ID API Gateway assigned to the request, as
+// reported in the event request context, or ID CloudFront assigned to the
+// request for Lambda@Edge events. This is the ID API Gateway access logs
+// use, and it differs from the ID of the Lambda invocation, see
+// LambdaRequestID. It returns an empty string if ID is not known.
+func GatewayRequestID(ctx context.Context) string {
+	if evt := eventFromContext(ctx); evt != nil {
+		return evt.RequestContext.RequestID
+	}
+	if evt, ok := ctx.Value(v1EventKey).(*events.APIGatewayProxyRequest); ok {
+		return evt.RequestContext.RequestID
+	}
+	if evt, ok := ctx.Value(wsEventKey).(*events.APIGatewayWebsocketProxyRequest); ok {
+		return evt.RequestContext.RequestID
+	}
+	if evt, ok := ctx.Value(edgeEventKey).(*EdgeEvent); ok {
+		return evt.Records[0].CF.Config.RequestID
+	}
+	return ""
+}
+
+// LambdaRequestID returns ID of the Lambda invocation that serves the
+// request, the one Lambda logs use. It returns an empty string if ID is not
+// known.
+func LambdaRequestID(ctx context.Context) string {
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		return lc.AwsRequestID
+	}
+	return ""
+}
+
+// OriginalPath returns request path exactly as it was delivered in the
+// event, before any modifications done by handler options, or an empty
+// string if ctx does not belong to a request created by this package.
+func OriginalPath(ctx context.Context) string {
+	if evt := eventFromContext(ctx); evt != nil {
+		return evt.RawPath
+	}
+	if evt, ok := ctx.Value(v1EventKey).(*events.APIGatewayProxyRequest); ok {
+		return evt.Path
+	}
+	if evt, ok := ctx.Value(albEventKey).(*events.ALBTargetGroupRequest); ok {
+		return evt.Path
+	}
+	if evt, ok := ctx.Value(edgeEventKey).(*EdgeEvent); ok {
+		return evt.Records[0].CF.Request.URI
+	}
+	return ""
+}
+
+// RequestSource identifies the kind of AWS service that delivered the
+// request, see Source.
+type RequestSource int
+
+const (
+	// SourceUnknown is reported for contexts not belonging to a request
+	// created by this package, or for events that cannot be attributed.
+	SourceUnknown RequestSource = iota
+	// SourceFunctionURL is a Lambda Function URL.
+	SourceFunctionURL
+	// SourceHTTPAPI is an API Gateway HTTP API.
+	SourceHTTPAPI
+	// SourceALB is an Application Load Balancer, see ALBHandler.
+	SourceALB
+	// SourceWebSocket is an API Gateway WebSocket API, see
+	// WebSocketHandler.
+	SourceWebSocket
+	// SourceRESTAPI is an API Gateway REST API, see HandlerV1.
+	SourceRESTAPI
+	// SourceCloudFront is a CloudFront Lambda@Edge trigger, see
+	// EdgeHandler.
+	SourceCloudFront
+)
+
+// Source reports which kind of service delivered the request.
+//
+// Function URLs and HTTP APIs use the same event format, so they are told
+// apart by the event request context: an event with domain name of the form
+// "<url-id>.lambda-url.<region>.on.aws" comes from a Function URL, otherwise
+// an event that has either API ID or stage set comes from an HTTP API. Note
+// that a Function URL behind a CloudFront distribution still reports its own
+// domain name in the event.
+func Source(ctx context.Context) RequestSource {
+	if evt := eventFromContext(ctx); evt != nil {
+		rc := &evt.RequestContext
+		switch {
+		case strings.Contains(rc.DomainName, ".lambda-url."):
+			return SourceFunctionURL
+		case rc.APIID != "" || rc.Stage != "":
+			return SourceHTTPAPI
+		}
+		return SourceUnknown
+	}
+	if _, ok := ctx.Value(v1EventKey).(*events.APIGatewayProxyRequest); ok {
+		return SourceRESTAPI
+	}
+	if _, ok := ctx.Value(albEventKey).(*events.ALBTargetGroupRequest); ok {
+		return SourceALB
+	}
+	if _, ok := ctx.Value(wsEventKey).(*events.APIGatewayWebsocketProxyRequest); ok {
+		return SourceWebSocket
+	}
+	if _, ok := ctx.Value(edgeEventKey).(*EdgeEvent); ok {
+		return SourceCloudFront
+	}
+	return SourceUnknown
+}
+
+// TargetGroupARN returns ARN of the target group that routed the request to
+// the function, or an empty string if request was not delivered by an
+// Application Load Balancer.
+func TargetGroupARN(ctx context.Context) string {
+	if evt, ok := ctx.Value(albEventKey).(*events.ALBTargetGroupRequest); ok {
+		return evt.RequestContext.ELB.TargetGroupArn
+	}
+	return ""
+}
+
+// RequestBodySize returns size of the request body delivered in the event,
+// after base64 decoding, if it was base64-encoded. Unlike r.ContentLength,
+// it is not affected by middleware replacing r, and remains available after
+// the body is consumed. It returns -1 if ctx does not belong to a request
+// created by this package.
+func RequestBodySize(ctx context.Context) int64 {
+	if evt := eventFromContext(ctx); evt != nil {
+		return bodySize(evt.Body, evt.IsBase64Encoded)
+	}
+	if evt, ok := ctx.Value(v1EventKey).(*events.APIGatewayProxyRequest); ok {
+		return bodySize(evt.Body, evt.IsBase64Encoded)
+	}
+	if evt, ok := ctx.Value(albEventKey).(*events.ALBTargetGroupRequest); ok {
+		return bodySize(evt.Body, evt.IsBase64Encoded)
+	}
+	if evt, ok := ctx.Value(wsEventKey).(*events.APIGatewayWebsocketProxyRequest); ok {
+		return bodySize(evt.Body, evt.IsBase64Encoded)
+	}
+	return -1
+}
+
+// bodySize returns size of the event body after decoding.
+func bodySize(body string, isBase64 bool) int64 {
+	if !isBase64 {
+		return int64(len(body))
+	}
+	n := len(body) / 4 * 3
+	switch {
+	case strings.HasSuffix(body, "=="):
+		n -= 2
+	case strings.HasSuffix(body, "="):
+		n--
+	}
+	return int64(n)
+}
+
+// RouteKey returns route key of the API Gateway route that matched the
+// request, such as "POST /orders/{id}", as reported in the event. It is
+// "$default" for Lambda Function URLs and for requests matched by the
+// default route, and an empty string if ctx does not belong to a request
+// created by Handler or WebSocketHandler.
+func RouteKey(ctx context.Context) string {
+	if evt := eventFromContext(ctx); evt != nil {
+		return evt.RouteKey
+	}
+	if evt, ok := ctx.Value(wsEventKey).(*events.APIGatewayWebsocketProxyRequest); ok {
+		return evt.RequestContext.RouteKey
+	}
+	return ""
+}
+
+// PathParameters returns path parameters API Gateway extracted from the
+// request path according to the matched route, such as "id" for the
+// "GET /users/{id}" route, as delivered in the event. They are also available
+// with r.PathValue. It returns nil if there are none, or if ctx does not
+// belong to a request created by this package.
+func PathParameters(ctx context.Context) map[string]string {
+	if evt := eventFromContext(ctx); evt != nil {
+		return evt.PathParameters
+	}
+	if evt := EventV1(ctx); evt != nil {
+		return evt.PathParameters
+	}
+	return nil
+}
+
+// Values returns a map for storing arbitrary request-scoped values, such as
+// ones some middleware passes to the handlers it wraps, without defining
+// dedicated context keys. Each request gets a new empty map, which is
+// discarded once the request is served. Values returns nil if ctx does not
+// belong to a request created by this package.
+func Values(ctx context.Context) *sync.Map {
+	m, _ := ctx.Value(valuesKey).(*sync.Map)
+	return m
+}
+
+// ConnectionInfo describes the client connection as seen by the service that
+// delivered the request, see ConnInfo.
+type ConnectionInfo struct {
+	SourceIP  string // client IP address
+	Proto     string // protocol of the client request, such as "HTTP/2.0"
+	TLS       bool   // whether client connected over TLS
+	UserAgent string // User-Agent of the client
+}
+
+// ConnInfo returns information about the client connection sourced from the
+// event, suitable for access logs. Function URLs, HTTP APIs, REST APIs and
+// WebSocket APIs only accept TLS connections. For ALB requests it relies on the
+// X-Forwarded-For and X-Forwarded-Proto headers set by the load balancer, and
+// Proto is left empty, as ALB does not report it. For Lambda@Edge requests TLS
+// is only reported if CloudFront is configured to forward the
+// CloudFront-Forwarded-Proto header. ConnInfo returns zero value
+// if ctx does not belong to a request created by this package.
+func ConnInfo(ctx context.Context) ConnectionInfo {
+	if evt := eventFromContext(ctx); evt != nil {
+		h := &evt.RequestContext.HTTP
+		return ConnectionInfo{
+			SourceIP:  h.SourceIP,
+			Proto:     h.Protocol,
+			TLS:       true,
+			UserAgent: h.UserAgent,
+		}
+	}
+	if evt, ok := ctx.Value(v1EventKey).(*events.APIGatewayProxyRequest); ok {
+		return ConnectionInfo{
+			SourceIP:  evt.RequestContext.Identity.SourceIP,
+			Proto:     evt.RequestContext.Protocol,
+			TLS:       true,
+			UserAgent: evt.RequestContext.Identity.UserAgent,
+		}
+	}
+	if evt, ok := ctx.Value(albEventKey).(*events.ALBTargetGroupRequest); ok {
+		return ConnectionInfo{
+			SourceIP:  albClientIP(albHeader(evt, "X-Forwarded-For")),
+			TLS:       strings.EqualFold(albHeader(evt, "X-Forwarded-Proto"), "https"),
+			UserAgent: albHeader(evt, "User-Agent"),
+		}
+	}
+	if evt, ok := ctx.Value(wsEventKey).(*events.APIGatewayWebsocketProxyRequest); ok {
+		return ConnectionInfo{
+			SourceIP:  evt.RequestContext.Identity.SourceIP,
+			TLS:       true,
+			UserAgent: evt.RequestContext.Identity.UserAgent,
+		}
+	}
+	if evt, ok := ctx.Value(edgeEventKey).(*EdgeEvent); ok {
+		req := &evt.Records[0].CF.Request
+		return ConnectionInfo{
+			SourceIP:  req.ClientIP,
+			TLS:       strings.EqualFold(req.Headers.get("cloudfront-forwarded-proto"), "https"),
+			UserAgent: req.Headers.get("user-agent"),
+		}
+	}
+	return ConnectionInfo{}
+}
+
+// albClientIP returns client IP address from the X-Forwarded-For header
+// value of ALB request. ALB appends address of the client connected to it to
+// the header, so the last entry is used: entries before it come from the
+// client, and cannot be trusted.
+func albClientIP(xff string) string {
+	if i := strings.LastIndexByte(xff, ','); i >= 0 {
+		xff = xff[i+1:]
+	}
+	return strings.TrimSpace(xff)
+}
+
+// albHeader returns the first value of the named header of ALB event,
+// regardless of the headers mode of the target group.
+func albHeader(evt *events.ALBTargetGroupRequest, name string) string {
+	for k, vv := range evt.MultiValueHeaders {
+		if strings.EqualFold(k, name) && len(vv) != 0 {
+			return vv[0]
+		}
+	}
+	for k, v := range evt.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
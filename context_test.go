@@ -0,0 +1,343 @@
+package apig
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+func TestRawQueryString(t *testing.T) {
+	for _, query := range []string{
+		"a[]=1&a[]=2",
+		"a%5B%5D=1&a%5B%5D=2",
+		"b=2&a=1&b=3",
+		"x;y=1|z",
+		"flag&=&k==v",
+	} {
+		var raw, fromCtx string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw, fromCtx = r.URL.RawQuery, RawQueryString(r.Context())
+		})
+		invoke(t, h, testEvent("GET", "/?"+query))
+		if raw != query {
+			t.Errorf("got r.URL.RawQuery %q, want %q", raw, query)
+		}
+		if fromCtx != query {
+			t.Errorf("got RawQueryString %q, want %q", fromCtx, query)
+		}
+	}
+}
+
+func TestRequestTime(t *testing.T) {
+	for _, tc := range []struct {
+		epoch int64
+		want  time.Time
+	}{
+		{1700000000123, time.UnixMilli(1700000000123)},
+		{0, time.Time{}},
+	} {
+		var got time.Time
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = RequestTime(r.Context()) })
+		evt := testEvent("GET", "/")
+		evt.RequestContext.TimeEpoch = tc.epoch
+		invoke(t, h, evt)
+		if !got.Equal(tc.want) {
+			t.Errorf("epoch %d: got %v, want %v", tc.epoch, got, tc.want)
+		}
+	}
+	if got := RequestTime(context.Background()); !got.IsZero() {
+		t.Errorf("outside of request: got %v, want zero time", got)
+	}
+}
+
+func TestPercentEncodedQuery(t *testing.T) {
+	const query = "q=hello%20world&t=a%2Bb&p=1+2"
+	var raw string
+	var got map[string][]string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { raw, got = r.URL.RawQuery, r.URL.Query() })
+	invoke(t, h, testEvent("GET", "/search?"+query))
+	if raw != query {
+		t.Errorf("got raw query %q, want %q", raw, query)
+	}
+	for k, want := range map[string]string{"q": "hello world", "t": "a+b", "p": "1 2"} {
+		if v := got[k]; len(v) != 1 || v[0] != want {
+			t.Errorf("query parameter %q: got %q, want %q", k, v, want)
+		}
+	}
+}
+
+func TestRequestIDs(t *testing.T) {
+	for _, tc := range []struct {
+		name                string
+		gatewayID, lambdaID string
+	}{
+		{"both", "gw-1", "lambda-1"},
+		{"gateway only", "gw-2", ""},
+		{"lambda only", "", "lambda-3"},
+	} {
+		var gotGateway, gotLambda string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotGateway, gotLambda = GatewayRequestID(r.Context()), LambdaRequestID(r.Context())
+		})
+		ctx := context.Background()
+		if tc.lambdaID != "" {
+			ctx = lambdacontext.NewContext(ctx, &lambdacontext.LambdaContext{AwsRequestID: tc.lambdaID})
+		}
+		evt := testEvent("GET", "/")
+		evt.RequestContext.RequestID = tc.gatewayID
+		if _, err := Handler(h)(ctx, evt); err != nil {
+			t.Fatal(err)
+		}
+		if gotGateway != tc.gatewayID || gotLambda != tc.lambdaID {
+			t.Errorf("%s: got gateway ID %q, Lambda ID %q, want %q and %q",
+				tc.name, gotGateway, gotLambda, tc.gatewayID, tc.lambdaID)
+		}
+	}
+}
+
+func TestOriginalPath(t *testing.T) {
+	var path, original string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, original = r.URL.Path, OriginalPath(r.Context())
+	})
+	invoke(t, h, testEvent("GET", "/prod/api/users?x=1"), WithBasePath("/prod/api"))
+	if path != "/users" || original != "/prod/api/users" {
+		t.Errorf("got path %q, original path %q", path, original)
+	}
+
+	path, original = "", ""
+	if _, err := HandlerV1(h, WithBasePath("/prod"))(context.Background(), &events.APIGatewayProxyRequest{
+		HTTPMethod: "GET",
+		Path:       "/prod/users",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/users" || original != "/prod/users" {
+		t.Errorf("HandlerV1: got path %q, original path %q", path, original)
+	}
+	if got := OriginalPath(context.Background()); got != "" {
+		t.Errorf("outside of request: got %q", got)
+	}
+}
+
+func TestSource(t *testing.T) {
+	var got RequestSource
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = Source(r.Context()) })
+	for _, tc := range []struct {
+		name          string
+		domain, apiID string
+		stage         string
+		want          RequestSource
+	}{
+		{"function url", "abc.lambda-url.us-east-1.on.aws", "", "", SourceFunctionURL},
+		{"http api", "abc.execute-api.us-east-1.amazonaws.com", "abc", "$default", SourceHTTPAPI},
+		{"http api custom domain", "api.example.com", "abc", "prod", SourceHTTPAPI},
+		{"unknown", "example.com", "", "", SourceUnknown},
+	} {
+		got = -1
+		evt := testEvent("GET", "/")
+		evt.RequestContext.DomainName = tc.domain
+		evt.RequestContext.APIID = tc.apiID
+		evt.RequestContext.Stage = tc.stage
+		invoke(t, h, evt)
+		if got != tc.want {
+			t.Errorf("%s: got source %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	ctx := context.Background()
+	got = -1
+	HandlerV1(h)(ctx, &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/"})
+	if got != SourceRESTAPI {
+		t.Errorf("HandlerV1: got source %v", got)
+	}
+	got = -1
+	ALBHandler(h)(ctx, &events.ALBTargetGroupRequest{HTTPMethod: "GET", Path: "/"})
+	if got != SourceALB {
+		t.Errorf("ALBHandler: got source %v", got)
+	}
+	got = -1
+	WebSocketHandler(h)(ctx, &events.APIGatewayWebsocketProxyRequest{})
+	if got != SourceWebSocket {
+		t.Errorf("WebSocketHandler: got source %v", got)
+	}
+	if got := Source(ctx); got != SourceUnknown {
+		t.Errorf("outside of request: got source %v", got)
+	}
+}
+
+func TestRequestBodySize(t *testing.T) {
+	for _, tc := range []struct {
+		body     string
+		isBase64 bool
+		want     int64
+	}{
+		{"", false, 0},
+		{"hello", false, 5},
+		{"aGVsbG8=", true, 5},
+		{"aGVsbG8h", true, 6},
+		{"aA==", true, 1},
+	} {
+		var size, contentLength int64
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.ReadAll(r.Body)
+			size, contentLength = RequestBodySize(r.Context()), r.ContentLength
+		})
+		evt := testEvent("POST", "/")
+		evt.Body, evt.IsBase64Encoded = tc.body, tc.isBase64
+		invoke(t, h, evt)
+		if size != tc.want || contentLength != tc.want {
+			t.Errorf("body %q (base64 %v): got size %d, ContentLength %d, want %d",
+				tc.body, tc.isBase64, size, contentLength, tc.want)
+		}
+	}
+	if got := RequestBodySize(context.Background()); got != -1 {
+		t.Errorf("outside of request: got %d, want -1", got)
+	}
+}
+
+func TestRouteKey(t *testing.T) {
+	var got string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = RouteKey(r.Context()) })
+	evt := testEvent("POST", "/orders/42")
+	evt.RouteKey = "POST /orders/{id}"
+	invoke(t, h, evt)
+	if got != "POST /orders/{id}" {
+		t.Errorf("got route key %q", got)
+	}
+	req := &events.APIGatewayWebsocketProxyRequest{}
+	req.RequestContext.RouteKey = "sendmessage"
+	WebSocketHandler(h)(context.Background(), req)
+	if got != "sendmessage" {
+		t.Errorf("WebSocketHandler: got route key %q", got)
+	}
+	if got := RouteKey(context.Background()); got != "" {
+		t.Errorf("outside of request: got %q", got)
+	}
+}
+
+func TestValues(t *testing.T) {
+	var got []any
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, loaded := Values(r.Context()).LoadOrStore("user", r.URL.Path); loaded {
+				t.Error("values map is not empty")
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, _ := Values(r.Context()).Load("user")
+		got = append(got, v)
+	}))
+	invoke(t, h, testEvent("GET", "/alice"))
+	invoke(t, h, testEvent("GET", "/bob"))
+	if len(got) != 2 || got[0] != "/alice" || got[1] != "/bob" {
+		t.Errorf("got values %v", got)
+	}
+	if Values(context.Background()) != nil {
+		t.Error("outside of request: got non-nil map")
+	}
+}
+
+func TestConnInfo(t *testing.T) {
+	var got ConnectionInfo
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = ConnInfo(r.Context()) })
+	ctx := context.Background()
+
+	evt := testEvent("GET", "/")
+	evt.RequestContext.HTTP.Protocol = "HTTP/2.0"
+	evt.RequestContext.HTTP.UserAgent = "curl/8.0"
+	invoke(t, h, evt)
+	if want := (ConnectionInfo{"192.0.2.1", "HTTP/2.0", true, "curl/8.0"}); got != want {
+		t.Errorf("Handler: got %+v, want %+v", got, want)
+	}
+
+	req := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/"}
+	req.RequestContext.Protocol = "HTTP/1.1"
+	req.RequestContext.Identity.SourceIP = "192.0.2.2"
+	req.RequestContext.Identity.UserAgent = "agent/1"
+	HandlerV1(h)(ctx, req)
+	if want := (ConnectionInfo{"192.0.2.2", "HTTP/1.1", true, "agent/1"}); got != want {
+		t.Errorf("HandlerV1: got %+v, want %+v", got, want)
+	}
+
+	ALBHandler(h)(ctx, &events.ALBTargetGroupRequest{HTTPMethod: "GET", Path: "/", Headers: map[string]string{
+		"host":              "example.com",
+		"x-forwarded-for":   "198.51.100.1, 192.0.2.3",
+		"x-forwarded-proto": "http",
+		"user-agent":        "agent/2",
+	}})
+	if want := (ConnectionInfo{SourceIP: "192.0.2.3", UserAgent: "agent/2"}); got != want {
+		t.Errorf("ALBHandler: got %+v, want %+v", got, want)
+	}
+
+	if got := ConnInfo(ctx); got != (ConnectionInfo{}) {
+		t.Errorf("outside of request: got %+v", got)
+	}
+}
+
+func TestEventAccessors(t *testing.T) {
+	var (
+		evt   *events.APIGatewayV2HTTPRequest
+		rc    *events.APIGatewayV2HTTPRequestContext
+		evtV1 *events.APIGatewayProxyRequest
+	)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		evt, rc, evtV1 = Event(r.Context()), RequestContext(r.Context()), EventV1(r.Context())
+	})
+	req := testEvent("GET", "/")
+	req.RequestContext.RequestID = "gw-1"
+	req.RequestContext.Stage = "prod"
+	req.RequestContext.TimeEpoch = 1700000000000
+	invoke(t, h, req)
+	if evt == nil || evt.RequestContext.RequestID != "gw-1" || evtV1 != nil {
+		t.Errorf("Handler: got event %+v, v1 event %+v", evt, evtV1)
+	}
+	if rc == nil || rc.Stage != "prod" || rc.TimeEpoch != 1700000000000 ||
+		rc.DomainName != "example.lambda-url.us-east-1.on.aws" {
+		t.Errorf("Handler: got request context %+v", rc)
+	}
+
+	reqV1 := &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/"}
+	reqV1.RequestContext.Stage = "v1"
+	HandlerV1(h)(context.Background(), reqV1)
+	if evt != nil || rc != nil || evtV1 == nil || evtV1.RequestContext.Stage != "v1" {
+		t.Errorf("HandlerV1: got event %+v, request context %+v, v1 event %+v", evt, rc, evtV1)
+	}
+
+	ctx := context.Background()
+	if Event(ctx) != nil || RequestContext(ctx) != nil || EventV1(ctx) != nil {
+		t.Error("outside of request: got non-nil event")
+	}
+}
+
+func TestPathParameters(t *testing.T) {
+	var id, name string
+	var params map[string]string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, name, params = r.PathValue("id"), r.PathValue("name"), PathParameters(r.Context())
+	})
+	evt := testEvent("GET", "/users/42/files/a b")
+	evt.PathParameters = map[string]string{"id": "42", "name": "a b"}
+	invoke(t, h, evt)
+	if id != "42" || name != "a b" || len(params) != 2 || params["id"] != "42" {
+		t.Errorf("Handler: got id %q, name %q, parameters %v", id, name, params)
+	}
+
+	HandlerV1(h)(context.Background(), &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/users/7",
+		PathParameters: map[string]string{"id": "7"}})
+	if id != "7" || name != "" || params["id"] != "7" {
+		t.Errorf("HandlerV1: got id %q, name %q, parameters %v", id, name, params)
+	}
+
+	invoke(t, h, testEvent("GET", "/"))
+	if id != "" || params != nil {
+		t.Errorf("no parameters: got id %q, parameters %v", id, params)
+	}
+}
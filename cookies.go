@@ -0,0 +1,66 @@
+package apig
+
+import "net/http"
+
+// WithValidateCookies configures handler to drop Set-Cookie response headers
+// that are not valid according to RFC 6265, such as the ones with illegal
+// characters in cookie names or values, which clients or API Gateway may
+// silently ignore. Dropped values are reported to the error logger.
+//
+// This option only applies to buffered responses.
+func WithValidateCookies() Option {
+	return func(c *config) { c.validateCookies = true }
+}
+
+// maxSetCookieBytes is the minimum size of a cookie RFC 6265 requires user
+// agents to support; larger cookies may be ignored.
+const maxSetCookieBytes = 4096
+
+// WithMaxCookieBytes limits the total size of Cookie request headers to n
+// bytes: requests exceeding it get 431 Request Header Fields Too Large
+// response without reaching the wrapped http.Handler. It also configures
+// handler to drop Set-Cookie response headers longer than 4096 bytes, which
+// user agents are not required to support, reporting them to the error
+// logger. Dropping of response cookies only applies to buffered responses.
+func WithMaxCookieBytes(n int) Option {
+	return func(c *config) { c.maxCookieBytes = n }
+}
+
+// cookiesTooLarge reports whether Cookie headers of r exceed the limit
+// configured with WithMaxCookieBytes.
+func (c *config) cookiesTooLarge(r *http.Request) bool {
+	if c.maxCookieBytes <= 0 {
+		return false
+	}
+	var size int
+	for _, v := range r.Header.Values("Cookie") {
+		size += len(v)
+	}
+	return size > c.maxCookieBytes
+}
+
+// filterCookies removes invalid (if WithValidateCookies is used) and
+// oversized (if WithMaxCookieBytes is used) Set-Cookie headers from res.
+func (h *lambdaHandler) filterCookies(r *http.Request, res *response) {
+	values := res.header.Values("Set-Cookie")
+	if len(values) == 0 {
+		return
+	}
+	valid := values[:0:0]
+	for _, v := range values {
+		if h.maxCookieBytes > 0 && len(v) > maxSetCookieBytes {
+			h.logf("apig: %s %s: dropping %d bytes long Set-Cookie header", r.Method, r.URL.Path, len(v))
+			continue
+		}
+		if h.validateCookies && len((&http.Response{Header: http.Header{"Set-Cookie": {v}}}).Cookies()) == 0 {
+			h.logf("apig: %s %s: dropping invalid Set-Cookie header %q", r.Method, r.URL.Path, v)
+			continue
+		}
+		valid = append(valid, v)
+	}
+	if len(valid) == 0 {
+		res.header.Del("Set-Cookie")
+		return
+	}
+	res.header["Set-Cookie"] = valid
+}
@@ -0,0 +1,217 @@
+package apig
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestRawCookies(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		opts          []Option
+		wantCookie    []string
+		wantCookies   []string
+		wantSetCookie []string
+	}{
+		{"default", nil, []string{"a=1", "b=2"}, []string{"s=1", "t=2"}, nil},
+		{"raw", []Option{WithRawCookies()}, []string{"custom"}, nil, []string{"s=1", "t=2"}},
+	} {
+		var cookie []string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cookie = r.Header.Values("Cookie")
+			w.Header().Add("Set-Cookie", "s=1")
+			w.Header().Add("Set-Cookie", "t=2")
+		})
+		evt := testEvent("GET", "/")
+		evt.Headers["cookie"] = "custom"
+		evt.Cookies = []string{"a=1", "b=2"}
+		out := invoke(t, h, evt, tc.opts...)
+		if !reflect.DeepEqual(cookie, tc.wantCookie) {
+			t.Errorf("%s: got Cookie header %q, want %q", tc.name, cookie, tc.wantCookie)
+		}
+		if !reflect.DeepEqual(out.Cookies, tc.wantCookies) {
+			t.Errorf("%s: got response cookies %q, want %q", tc.name, out.Cookies, tc.wantCookies)
+		}
+		if got := out.MultiValueHeaders["Set-Cookie"]; !reflect.DeepEqual(got, tc.wantSetCookie) {
+			t.Errorf("%s: got Set-Cookie headers %q, want %q", tc.name, got, tc.wantSetCookie)
+		}
+	}
+}
+
+func TestValidateCookies(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "good=1; Path=/")
+		w.Header().Add("Set-Cookie", "bad name=2")
+		w.Header().Add("Set-Cookie", "=nameless")
+	})
+	for _, tc := range []struct {
+		opts []Option
+		want []string
+	}{
+		{nil, []string{"good=1; Path=/", "bad name=2", "=nameless"}},
+		{[]Option{WithValidateCookies()}, []string{"good=1; Path=/"}},
+	} {
+		out := invoke(t, h, testEvent("GET", "/"), tc.opts...)
+		if !reflect.DeepEqual(out.Cookies, tc.want) {
+			t.Errorf("got cookies %q, want %q", out.Cookies, tc.want)
+		}
+	}
+}
+
+func TestCookiesDoNotAffectHost(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		cookies []string
+		want    string
+	}{
+		{"host header", map[string]string{"host": "api.example.com"}, []string{"host=evil.example.com", "Host=evil.example.com"}, "api.example.com"},
+		{"domain name", nil, []string{"host=evil.example.com"}, "abc.lambda-url.us-east-1.on.aws"},
+		{"cookie header", map[string]string{"host": "api.example.com", "cookie": "host=evil.example.com"}, []string{"a=1"}, "api.example.com"},
+	} {
+		var host string
+		var cookies []*http.Cookie
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { host, cookies = r.Host, r.Cookies() })
+		evt := testEvent("GET", "/")
+		evt.Headers = tc.headers
+		evt.Cookies = tc.cookies
+		evt.RequestContext.DomainName = "abc.lambda-url.us-east-1.on.aws"
+		invoke(t, h, evt)
+		if host != tc.want {
+			t.Errorf("%s: got r.Host %q, want %q", tc.name, host, tc.want)
+		}
+		if len(cookies) != len(tc.cookies) {
+			t.Errorf("%s: got cookies %v, want %q", tc.name, cookies, tc.cookies)
+		}
+	}
+}
+
+func TestSameNameCookies(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "", Path: "/old", MaxAge: -1})
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "new", Path: "/"})
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "sub", Path: "/", Domain: "app.example.com"})
+	})
+	want := []string{
+		"session=; Path=/old; Max-Age=0",
+		"session=new; Path=/",
+		"session=sub; Path=/; Domain=app.example.com",
+	}
+	for _, opts := range [][]Option{nil, {WithValidateCookies()}} {
+		out := invoke(t, h, testEvent("GET", "/"), opts...)
+		if !reflect.DeepEqual(out.Cookies, want) {
+			t.Errorf("with %d options: got cookies %q, want %q", len(opts), out.Cookies, want)
+		}
+	}
+}
+
+func TestMaxCookieBytes(t *testing.T) {
+	large := "big=" + strings.Repeat("x", 5000)
+	for _, tc := range []struct {
+		name        string
+		cookies     []string
+		wantCode    int
+		wantCookies []string
+	}{
+		{"within limit", []string{"a=1", "b=2"}, http.StatusOK, []string{"small=1"}},
+		{"request too large", []string{"a=" + strings.Repeat("x", 100)}, http.StatusRequestHeaderFieldsTooLarge, nil},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Set-Cookie", "small=1")
+			w.Header().Add("Set-Cookie", large)
+		})
+		evt := testEvent("GET", "/")
+		evt.Cookies = tc.cookies
+		out := invoke(t, h, evt, WithMaxCookieBytes(64))
+		if out.StatusCode != tc.wantCode {
+			t.Errorf("%s: got status %d, want %d", tc.name, out.StatusCode, tc.wantCode)
+		}
+		if !reflect.DeepEqual(out.Cookies, tc.wantCookies) {
+			t.Errorf("%s: got %d response cookies, want %q", tc.name, len(out.Cookies), tc.wantCookies)
+		}
+	}
+}
+
+func TestMultipleSetCookieV1ALB(t *testing.T) {
+	cookies := []string{"a=1; Path=/", "b=2; HttpOnly"}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := 1
+		if r.URL.Path == "/two" {
+			n = 2
+		}
+		for _, c := range cookies[:n] {
+			w.Header().Add("Set-Cookie", c)
+		}
+	})
+	ctx := context.Background()
+	for path, want := range map[string][]string{"/one": cookies[:1], "/two": cookies} {
+		out, err := HandlerV1(h)(ctx, &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := out.MultiValueHeaders["Set-Cookie"]; !reflect.DeepEqual(got, want) || out.Headers["Set-Cookie"] != "" {
+			t.Errorf("HandlerV1 %s: got Set-Cookie %q in multi-value headers, %q in headers, want %q",
+				path, got, out.Headers["Set-Cookie"], want)
+		}
+
+		alb, err := ALBHandler(h)(ctx, &events.ALBTargetGroupRequest{
+			HTTPMethod: "GET", Path: path, MultiValueHeaders: map[string][]string{"host": {"example.com"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := alb.MultiValueHeaders["Set-Cookie"]; !reflect.DeepEqual(got, want) || alb.Headers != nil {
+			t.Errorf("ALBHandler %s: got Set-Cookie %q in multi-value headers, headers %v, want %q",
+				path, got, alb.Headers, want)
+		}
+	}
+
+	// without multi-value headers enabled on the target group ALB can only
+	// send one cookie
+	var buf bytes.Buffer
+	alb, err := ALBHandler(h, WithErrorLogger(log.New(&buf, "", 0)))(ctx, &events.ALBTargetGroupRequest{
+		HTTPMethod: "GET", Path: "/two", Headers: map[string]string{"host": "example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alb.Headers["Set-Cookie"] != cookies[0] || !strings.Contains(buf.String(), "dropping 1 of 2 Set-Cookie headers") {
+		t.Errorf("ALBHandler single-value: got Set-Cookie %q, log %q", alb.Headers["Set-Cookie"], buf.String())
+	}
+}
+
+func TestSetCookieHeaderCase(t *testing.T) {
+	cookies := []string{"a=1; Path=/", "b=2; HttpOnly"}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := 1
+		if r.URL.Path == "/two" {
+			n = 2
+		}
+		w.Header()["set-cookie"] = cookies[:n]
+	})
+	ctx := context.Background()
+	for path, want := range map[string][]string{"/one": cookies[:1], "/two": cookies} {
+		out, err := HandlerV1(h, WithPreserveHeaderCase())(ctx, &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := out.MultiValueHeaders["set-cookie"]; !reflect.DeepEqual(got, want) || out.Headers["set-cookie"] != "" {
+			t.Errorf("HandlerV1 %s: got set-cookie %q in multi-value headers, %q in headers, want %q",
+				path, got, out.Headers["set-cookie"], want)
+		}
+	}
+	alb, err := ALBHandler(h, WithPreserveHeaderCase(), WithErrorLogger(log.New(io.Discard, "", 0)))(ctx,
+		&events.ALBTargetGroupRequest{HTTPMethod: "GET", Path: "/two", Headers: map[string]string{"host": "example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := alb.Headers["set-cookie"]; got != cookies[0] {
+		t.Errorf("ALBHandler single-value: got set-cookie %q, want %q", got, cookies[0])
+	}
+}
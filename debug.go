@@ -0,0 +1,73 @@
+package apig
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+)
+
+// WithDebugDump configures handler to write dumps of every request passed to
+// the wrapped http.Handler and its response to w, in the format of
+// httputil.DumpRequest and httputil.DumpResponse. Dumps include bodies, so
+// this option is only meant for debugging.
+func WithDebugDump(w io.Writer) Option {
+	return func(c *config) { c.debugDump = w }
+}
+
+// WithDebugDumpBodyLimit limits the size of bodies in dumps written by
+// handler configured with WithDebugDump to the first n bytes; longer bodies
+// are truncated, and a marker with the full body size follows them.
+func WithDebugDumpBodyLimit(n int) Option {
+	return func(c *config) { c.debugDumpLimit = n }
+}
+
+func (h *lambdaHandler) dumpRequest(r *http.Request) {
+	b, err := httputil.DumpRequest(r, h.debugDumpLimit <= 0)
+	if err != nil {
+		h.logf("apig: dumping request: %v", err)
+		return
+	}
+	if h.debugDumpLimit > 0 && r.Body != nil {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			h.logf("apig: dumping request: %v", err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		b = append(b, h.bodyPreview(body)...)
+	}
+	h.debugDump.Write(append(append([]byte("--- request\n"), b...), '\n'))
+}
+
+// bodyPreview returns body truncated to the limit configured with
+// WithDebugDumpBodyLimit.
+func (h *lambdaHandler) bodyPreview(body []byte) []byte {
+	if len(body) <= h.debugDumpLimit {
+		return body
+	}
+	out := append([]byte(nil), body[:h.debugDumpLimit]...)
+	return append(out, "...[truncated, "+strconv.Itoa(len(body))+" bytes total]"...)
+}
+
+func (h *lambdaHandler) dumpResponse(r *http.Request, res *response) {
+	b, err := httputil.DumpResponse(&http.Response{
+		StatusCode:    res.status,
+		Status:        strconv.Itoa(res.status) + " " + http.StatusText(res.status),
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Request:       r,
+		Header:        res.header,
+		Body:          io.NopCloser(bytes.NewReader(res.body)),
+		ContentLength: int64(len(res.body)),
+	}, h.debugDumpLimit <= 0)
+	if err != nil {
+		h.logf("apig: dumping response: %v", err)
+		return
+	}
+	if h.debugDumpLimit > 0 {
+		b = append(b, h.bodyPreview(res.body)...)
+	}
+	h.debugDump.Write(append(append([]byte("--- response\n"), b...), '\n'))
+}
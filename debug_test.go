@@ -0,0 +1,67 @@
+package apig
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDebugDump(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Reply", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(b)
+	})
+	var dump bytes.Buffer
+	evt := testEvent("POST", "/items?x=1")
+	evt.Headers["x-custom"] = "v"
+	evt.Body = "request body"
+	out := invoke(t, h, evt, WithDebugDump(&dump))
+	if out.Body != "request body" {
+		t.Errorf("got response body %q, want request body echoed back", out.Body)
+	}
+	for _, want := range []string{
+		"--- request\nPOST /items?x=1 HTTP/1.1\r\n",
+		"X-Custom: v\r\n",
+		"\r\n\r\nrequest body",
+		"--- response\nHTTP/1.1 201 Created\r\n",
+		"X-Reply: 1\r\n",
+	} {
+		if !strings.Contains(dump.String(), want) {
+			t.Errorf("dump does not contain %q:\n%s", want, dump.String())
+		}
+	}
+
+}
+
+func TestDebugDumpBodyLimit(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write(bytes.ToUpper(b))
+	})
+	var dump bytes.Buffer
+	evt := testEvent("POST", "/")
+	evt.Body = "0123456789abcdef"
+	out := invoke(t, h, evt, WithDebugDump(&dump), WithDebugDumpBodyLimit(8))
+	if out.Body != "0123456789ABCDEF" {
+		t.Errorf("got response body %q, want the whole body", out.Body)
+	}
+	const preview = "\r\n\r\n01234567...[truncated, 16 bytes total]\n"
+	if !strings.Contains(dump.String(), preview+"--- response\n") || !strings.HasSuffix(dump.String(), preview) {
+		t.Errorf("dump does not have truncated bodies:\n%s", dump.String())
+	}
+	if strings.Contains(dump.String(), "89ab") || strings.Contains(dump.String(), "89AB") {
+		t.Errorf("dump contains untruncated body:\n%s", dump.String())
+	}
+
+	// bodies within the limit are dumped as is
+	dump.Reset()
+	evt.Body = "short"
+	invoke(t, h, evt, WithDebugDump(&dump), WithDebugDumpBodyLimit(8))
+	if !strings.Contains(dump.String(), "\r\n\r\nshort\n") || strings.Contains(dump.String(), "truncated") {
+		t.Errorf("got dump:\n%s", dump.String())
+	}
+}
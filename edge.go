@@ -0,0 +1,231 @@
+package apig
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// EdgeEvent is a Lambda@Edge event delivered for CloudFront viewer request
+// and origin request triggers, see EdgeHandler.
+type EdgeEvent struct {
+	Records []EdgeEventRecord `json:"Records"`
+}
+
+// EdgeEventRecord is a record of EdgeEvent.
+type EdgeEventRecord struct {
+	CF struct {
+		Config  EdgeConfig  `json:"config"`
+		Request EdgeRequest `json:"request"`
+	} `json:"cf"`
+}
+
+// EdgeConfig describes CloudFront distribution and trigger of EdgeEvent.
+type EdgeConfig struct {
+	DistributionDomainName string `json:"distributionDomainName"`
+	DistributionID         string `json:"distributionId"`
+	EventType              string `json:"eventType"`
+	RequestID              string `json:"requestId"`
+}
+
+// EdgeRequest is the CloudFront request of EdgeEvent. EdgeHandler returns it
+// to pass the request on to the origin.
+type EdgeRequest struct {
+	ClientIP    string          `json:"clientIp"`
+	Headers     EdgeHeaders     `json:"headers"`
+	Method      string          `json:"method"`
+	QueryString string          `json:"querystring"`
+	URI         string          `json:"uri"`
+	Body        *EdgeBody       `json:"body,omitempty"`
+	Origin      json.RawMessage `json:"origin,omitempty"`
+}
+
+// EdgeBody is the body of EdgeRequest, delivered if the trigger is
+// configured to include it.
+type EdgeBody struct {
+	InputTruncated bool   `json:"inputTruncated"`
+	Action         string `json:"action"`
+	Encoding       string `json:"encoding"` // "base64" or "text"
+	Data           string `json:"data"`
+}
+
+// EdgeHeaders holds CloudFront headers keyed by lowercase header names.
+type EdgeHeaders map[string][]EdgeHeader
+
+// get returns the first value of the header with lowercase name.
+func (hh EdgeHeaders) get(name string) string {
+	if vv := hh[name]; len(vv) != 0 {
+		return vv[0].Value
+	}
+	return ""
+}
+
+// EdgeHeader is a header value of EdgeHeaders.
+type EdgeHeader struct {
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value"`
+}
+
+// EdgeResponse is a response EdgeHandler returns to have CloudFront send it
+// to the viewer, instead of passing the request to the origin.
+type EdgeResponse struct {
+	Status            string      `json:"status"`
+	StatusDescription string      `json:"statusDescription,omitempty"`
+	Headers           EdgeHeaders `json:"headers,omitempty"`
+	Body              string      `json:"body,omitempty"`
+	BodyEncoding      string      `json:"bodyEncoding,omitempty"`
+}
+
+// EdgeHandler returns function suitable to use as an AWS Lambda handler with
+// github.com/aws/aws-lambda-go/lambda package for Lambda@Edge functions
+// associated with CloudFront viewer request or origin request events. It
+// allows reusing http.Handler middleware, such as ones doing authentication,
+// redirects or header rewriting, in front of CloudFront origins.
+//
+// Request is passed on to the origin if h calls EdgeOrigin, typically as the
+// innermost handler of middleware chain:
+//
+//	lambda.Start(apig.EdgeHandler(requireAuth(apig.EdgeOrigin)))
+//
+// Method, URL, Host and headers of the request EdgeOrigin is called with are
+// sent to the origin, so middleware can modify them, within the limits
+// CloudFront puts on changes of read-only headers; request body is passed
+// unchanged. Otherwise response written by h is sent to the viewer, and the
+// origin is not contacted. Function result is *EdgeRequest in the former
+// case, and *EdgeResponse in the latter.
+//
+// Request body is only available if the trigger is configured to include it,
+// and is truncated by CloudFront if it is too large. Note that both request
+// and response are fully cached in memory, and that CloudFront limits size
+// of generated responses.
+func EdgeHandler(h http.Handler, opts ...Option) func(context.Context, *EdgeEvent) (any, error) {
+	if h == nil {
+		panic("EdgeHandler called with nil argument")
+	}
+	return newHandler(h, opts).runEdge
+}
+
+// EdgeOrigin is an http.Handler which makes EdgeHandler pass the request to
+// the CloudFront origin, see EdgeHandler. Called outside of EdgeHandler, it
+// responds with 502 Bad Gateway.
+var EdgeOrigin http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	st, ok := r.Context().Value(edgeOriginKey).(*edgeState)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	st.forward = r
+})
+
+// EdgeEventFromContext returns Lambda@Edge event of the request, or nil if
+// request was not delivered by EdgeHandler.
+func EdgeEventFromContext(ctx context.Context) *EdgeEvent {
+	evt, _ := ctx.Value(edgeEventKey).(*EdgeEvent)
+	return evt
+}
+
+// edgeState records the request EdgeOrigin was called with.
+type edgeState struct {
+	forward *http.Request
+}
+
+func (h *lambdaHandler) runEdge(ctx context.Context, evt *EdgeEvent) (any, error) {
+	atomic.AddUint64(&invocations, 1)
+	if len(evt.Records) == 0 {
+		return nil, errUnknownEvent
+	}
+	rec := &evt.Records[0].CF
+	req := &rec.Request
+	toResponse := func(res *response) *EdgeResponse {
+		out := &EdgeResponse{
+			Status:            strconv.Itoa(res.status),
+			StatusDescription: strings.TrimPrefix(h.statusDescription(res.status), strconv.Itoa(res.status)+" "),
+			Headers:           edgeHeaders(res.header, ""),
+		}
+		body, isBase64 := h.encodeBody(res)
+		out.Body = body
+		if isBase64 {
+			out.BodyEncoding = "base64"
+		}
+		return out
+	}
+	method, ok := normalizeMethod(req.Method, h.extraMethods)
+	if !ok {
+		return toResponse(errorResponse(http.StatusBadRequest)), nil
+	}
+	headers := make(http.Header, len(req.Headers))
+	for k, vv := range req.Headers {
+		k = http.CanonicalHeaderKey(k)
+		for _, v := range vv {
+			headers[k] = append(headers[k], v.Value)
+		}
+	}
+	r := &http.Request{
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Proto:      "HTTP/1.1",
+		Method:     method,
+		URL:        &url.URL{Path: h.requestPath(method, req.URI), RawQuery: req.QueryString},
+		Header:     headers,
+		Host:       h.host(headers, rec.Config.DistributionDomainName),
+		RemoteAddr: req.ClientIP,
+	}
+	h.setProto(r, "")
+	setConn(r, strings.EqualFold(headers.Get("Cloudfront-Forwarded-Proto"), "https"))
+	st := new(edgeState)
+	ctx = context.WithValue(ctx, edgeEventKey, evt)
+	ctx = context.WithValue(ctx, edgeOriginKey, st)
+	r = r.WithContext(h.requestContext(ctx, rec.Config.RequestID, headers))
+	var body string
+	var isBase64 bool
+	if req.Body != nil {
+		body, isBase64 = req.Body.Data, req.Body.Encoding == "base64"
+	}
+	h.setBody(r, body, isBase64)
+	res := h.serve(r)
+	defer res.release()
+	if res.err != nil {
+		return nil, res.err
+	}
+	if fr := st.forward; fr != nil {
+		out := *req
+		out.Method = fr.Method
+		out.URI = fr.URL.Path
+		if fr.URL.RawPath != "" {
+			out.URI = fr.URL.EscapedPath()
+		}
+		out.QueryString = fr.URL.RawQuery
+		out.Headers = edgeHeaders(fr.Header, fr.Host)
+		return &out, nil
+	}
+	return toResponse(res), nil
+}
+
+// edgeHeaders converts header to the CloudFront format. If host is not
+// empty, it is used as the Host header.
+func edgeHeaders(header http.Header, host string) EdgeHeaders {
+	out := make(EdgeHeaders, len(header))
+	for k, vv := range header {
+		lk := strings.ToLower(k)
+		if lk == "host" && host != "" {
+			continue
+		}
+		if ck := http.CanonicalHeaderKey(k); ck != k {
+			// skip aliases added by WithPreserveHeaderCase
+			if _, ok := header[ck]; ok {
+				continue
+			}
+		}
+		for _, v := range vv {
+			out[lk] = append(out[lk], EdgeHeader{Key: k, Value: v})
+		}
+	}
+	if host != "" {
+		out["host"] = []EdgeHeader{{Key: "Host", Value: host}}
+	}
+	return out
+}
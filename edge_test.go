@@ -0,0 +1,97 @@
+package apig
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+)
+
+const testEdgeEvent = `{"Records":[{"cf":{
+	"config":{"distributionDomainName":"d111111abcdef8.cloudfront.net","distributionId":"EDFDVBD6EXAMPLE","eventType":"viewer-request","requestId":"4TyzHTaYWb1GX1qTfsHhEqV6HUDd_BzoBZnwfnvQc_1oF26ClkoUSEQ=="},
+	"request":{"clientIp":"203.0.113.178","method":"GET","uri":"/private/doc","querystring":"v=1",
+		"headers":{"host":[{"key":"Host","value":"example.org"}],"user-agent":[{"key":"User-Agent","value":"curl/8.0"}]}}}}]}`
+
+func edgeEvent(t *testing.T) *EdgeEvent {
+	t.Helper()
+	evt := new(EdgeEvent)
+	if err := json.Unmarshal([]byte(testEdgeEvent), evt); err != nil {
+		t.Fatal(err)
+	}
+	return evt
+}
+
+func TestEdgeHandler(t *testing.T) {
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("token") == "" {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusForbidden)
+				io.WriteString(w, "denied")
+				return
+			}
+			r.Header.Set("X-User", "alice")
+			r.URL.Path = "/docs" + r.URL.Path
+			next.ServeHTTP(w, r)
+		})
+	}
+	fn := EdgeHandler(auth(EdgeOrigin))
+
+	out, err := fn(context.Background(), edgeEvent(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, ok := out.(*EdgeResponse)
+	if !ok {
+		t.Fatalf("got %T, want generated response", out)
+	}
+	if res.Status != "403" || res.StatusDescription != "Forbidden" || res.Body != "denied" {
+		t.Errorf("got response %+v", res)
+	}
+	if got := res.Headers.get("content-type"); got != "text/plain" {
+		t.Errorf("got Content-Type %q", got)
+	}
+
+	evt := edgeEvent(t)
+	evt.Records[0].CF.Request.QueryString = "token=x"
+	if out, err = fn(context.Background(), evt); err != nil {
+		t.Fatal(err)
+	}
+	req, ok := out.(*EdgeRequest)
+	if !ok {
+		t.Fatalf("got %T, want request passed to origin", out)
+	}
+	if req.URI != "/docs/private/doc" || req.QueryString != "token=x" || req.Method != "GET" {
+		t.Errorf("got request %s %s?%s", req.Method, req.URI, req.QueryString)
+	}
+	for name, want := range map[string]string{"host": "example.org", "x-user": "alice", "user-agent": "curl/8.0"} {
+		if got := req.Headers.get(name); got != want {
+			t.Errorf("got %s header %q, want %q", name, got, want)
+		}
+	}
+	if req.ClientIP != "203.0.113.178" {
+		t.Errorf("got client IP %q", req.ClientIP)
+	}
+}
+
+func TestEdgeRequestContext(t *testing.T) {
+	var id string
+	var src RequestSource
+	var info ConnectionInfo
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, src, info = RequestID(r.Context()), Source(r.Context()), ConnInfo(r.Context())
+	})
+	if _, err := EdgeHandler(h)(context.Background(), edgeEvent(t)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "4TyzHTaYWb1GX1qTfsHhEqV6HUDd_BzoBZnwfnvQc_1oF26ClkoUSEQ=="; id != want {
+		t.Errorf("got request ID %q, want %q", id, want)
+	}
+	if src != SourceCloudFront {
+		t.Errorf("got source %v, want SourceCloudFront", src)
+	}
+	if info.SourceIP != "203.0.113.178" || info.UserAgent != "curl/8.0" {
+		t.Errorf("got connection info %+v", info)
+	}
+}
@@ -0,0 +1,183 @@
+package apig
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// HandleErr returns an http.Handler calling fn. If fn returns a non-nil
+// error, and has not written anything to w yet, HandleErr responds with
+// an error. If error is (or wraps) *HTTPError, response is built from it.
+// Otherwise HandleErr sends a plain text error; its status code is picked
+// according to WithErrorStatusFunc and WithErrorStatusMap options of the
+// handler serving the request, and is 500 Internal Server Error for errors
+// not matched by either of them. Such unmatched errors are logged to the
+// logger configured with WithErrorLogger.
+func HandleErr(fn func(http.ResponseWriter, *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tw := &trackingWriter{ResponseWriter: w}
+		err := fn(tw, r)
+		if err == nil || tw.wrote {
+			return
+		}
+		c, _ := r.Context().Value(configKey).(*config)
+		var herr *HTTPError
+		if errors.As(err, &herr) {
+			herr.write(w, c)
+			return
+		}
+		code := c.errorStatus(err)
+		if code == 0 {
+			code = http.StatusInternalServerError
+			if c != nil {
+				c.logf("apig: %s %s: %v", r.Method, r.URL.Path, err)
+			}
+		}
+		http.Error(w, http.StatusText(code), code)
+	})
+}
+
+// HTTPError is an error describing HTTP response, see HandleErr.
+type HTTPError struct {
+	Code        int    // response status code
+	Message     string // response body; status text is used if empty
+	ContentType string // Content-Type of Message, plain text if empty
+
+	// Payload, if not nil, is marshaled to JSON (see WithJSONCodec) and sent
+	// as the response body with application/json type instead of Message.
+	Payload any
+}
+
+// JSONError returns *HTTPError with the given status code and payload
+// sent as a JSON response body:
+//
+//	return apig.JSONError(http.StatusNotFound, map[string]any{
+//		"error": "no such user",
+//		"code":  http.StatusNotFound,
+//	})
+func JSONError(code int, payload any) error {
+	return &HTTPError{Code: code, Payload: payload}
+}
+
+func (e *HTTPError) Error() string {
+	if e.Message != "" {
+		return strconv.Itoa(e.Code) + " " + e.Message
+	}
+	return strconv.Itoa(e.Code) + " " + http.StatusText(e.Code)
+}
+
+// write sends e as a response to w. Config c may be nil.
+func (e *HTTPError) write(w http.ResponseWriter, c *config) {
+	code := e.Code
+	if code == 0 {
+		code = http.StatusInternalServerError
+	}
+	if e.Payload != nil {
+		var b []byte
+		var err error
+		if c != nil {
+			b, err = c.marshal(e.Payload)
+		} else {
+			b, err = json.Marshal(e.Payload)
+		}
+		if err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.WriteHeader(code)
+			w.Write(b)
+			return
+		}
+		if c != nil {
+			c.logf("apig: encoding error payload: %v", err)
+		}
+		code = http.StatusInternalServerError
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(code)
+	}
+	if e.ContentType == "" {
+		http.Error(w, msg, code)
+		return
+	}
+	w.Header().Set("Content-Type", e.ContentType)
+	w.WriteHeader(code)
+	io.WriteString(w, msg)
+}
+
+// WithErrorStatusMap configures status codes of responses HandleErr sends
+// when the wrapped function returns an error matching (as reported by
+// errors.Is) one of m keys. If an error matches several keys, which of them
+// is used is unspecified.
+//
+//	apig.WithErrorStatusMap(map[error]int{
+//		sql.ErrNoRows:            http.StatusNotFound,
+//		context.DeadlineExceeded: http.StatusGatewayTimeout,
+//	})
+func WithErrorStatusMap(m map[error]int) Option {
+	return func(c *config) { c.errorStatusMap = m }
+}
+
+// WithErrorStatusFunc configures a function used by HandleErr to map an
+// error to a response status code; fn should return 0 for errors it does not
+// recognize. It is consulted before the map configured with
+// WithErrorStatusMap, and is the way to match errors by their type:
+//
+//	apig.WithErrorStatusFunc(func(err error) int {
+//		var perr *fs.PathError
+//		if errors.As(err, &perr) {
+//			return http.StatusNotFound
+//		}
+//		return 0
+//	})
+func WithErrorStatusFunc(fn func(error) int) Option {
+	return func(c *config) { c.errorStatusFunc = fn }
+}
+
+// errorStatus returns status code for err, or 0 if err is not matched by
+// configured rules. It is safe to call on nil config.
+func (c *config) errorStatus(err error) int {
+	if c == nil {
+		return 0
+	}
+	if c.errorStatusFunc != nil {
+		if code := c.errorStatusFunc(err); code != 0 {
+			return code
+		}
+	}
+	for target, code := range c.errorStatusMap {
+		if errors.Is(err, target) {
+			return code
+		}
+	}
+	return 0
+}
+
+// trackingWriter records whether anything was written to the underlying
+// http.ResponseWriter.
+type trackingWriter struct {
+	http.ResponseWriter
+	wrote bool
+}
+
+func (w *trackingWriter) WriteHeader(code int) {
+	w.wrote = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *trackingWriter) Write(b []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *trackingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wrote = true
+		f.Flush()
+	}
+}
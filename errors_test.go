@@ -0,0 +1,75 @@
+package apig
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestErrorStatusMap(t *testing.T) {
+	opts := []Option{
+		WithErrorStatusMap(map[error]int{
+			sql.ErrNoRows:            http.StatusNotFound,
+			context.DeadlineExceeded: http.StatusGatewayTimeout,
+		}),
+		WithErrorStatusFunc(func(err error) int {
+			var perr *fs.PathError
+			if errors.As(err, &perr) {
+				return http.StatusForbidden
+			}
+			return 0
+		}),
+	}
+	for _, tc := range []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{"mapped", sql.ErrNoRows, http.StatusNotFound},
+		{"wrapped", fmt.Errorf("loading user: %w", sql.ErrNoRows), http.StatusNotFound},
+		{"deadline", context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{"by type", &fs.PathError{Op: "open", Path: "x", Err: fs.ErrPermission}, http.StatusForbidden},
+		{"unmapped", errors.New("boom"), http.StatusInternalServerError},
+		{"nil", nil, http.StatusOK},
+	} {
+		h := HandleErr(func(w http.ResponseWriter, r *http.Request) error { return tc.err })
+		out := invoke(t, h, testEvent("GET", "/"), opts...)
+		if out.StatusCode != tc.wantCode {
+			t.Errorf("%s: got status %d, want %d", tc.name, out.StatusCode, tc.wantCode)
+		}
+		if tc.err != nil && out.Body != http.StatusText(tc.wantCode)+"\n" {
+			t.Errorf("%s: got body %q", tc.name, out.Body)
+		}
+	}
+}
+
+func TestJSONError(t *testing.T) {
+	for _, tc := range []struct {
+		name             string
+		err              error
+		wantCode         int
+		wantCT, wantBody string
+	}{
+		{"json", JSONError(http.StatusNotFound, map[string]any{"error": "no such user", "code": 404}),
+			http.StatusNotFound, "application/json", `{"code":404,"error":"no such user"}`},
+		{"wrapped", fmt.Errorf("lookup: %w", JSONError(http.StatusConflict, []string{"taken"})),
+			http.StatusConflict, "application/json", `["taken"]`},
+		{"custom type", &HTTPError{Code: http.StatusBadRequest, Message: "<p>bad</p>", ContentType: "text/html"},
+			http.StatusBadRequest, "text/html", "<p>bad</p>"},
+		{"plain", &HTTPError{Code: http.StatusForbidden},
+			http.StatusForbidden, "text/plain; charset=utf-8", "Forbidden"},
+	} {
+		h := HandleErr(func(w http.ResponseWriter, r *http.Request) error { return tc.err })
+		out := invoke(t, h, testEvent("GET", "/"))
+		body := strings.TrimSuffix(out.Body, "\n")
+		if out.StatusCode != tc.wantCode || out.Headers["Content-Type"] != tc.wantCT || body != tc.wantBody {
+			t.Errorf("%s: got %d %q with Content-Type %q, want %d %q with %q", tc.name,
+				out.StatusCode, body, out.Headers["Content-Type"], tc.wantCode, tc.wantBody, tc.wantCT)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package apig_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/artyom/apig"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func newEvent(method, path string) *events.APIGatewayV2HTTPRequest {
+	evt := &events.APIGatewayV2HTTPRequest{
+		Version:  "2.0",
+		RouteKey: "$default",
+		RawPath:  path,
+		Headers:  map[string]string{"host": "example.lambda-url.us-east-1.on.aws"},
+	}
+	evt.RequestContext.HTTP.Method = method
+	evt.RequestContext.HTTP.Path = path
+	return evt
+}
+
+func ExampleHandler() {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Origin")
+		fmt.Fprintf(w, "%s %s", r.Method, r.URL.Path)
+	})
+	// use lambda.Start(apig.Handler(h)) in a real function
+	out, err := apig.Handler(h)(context.Background(), newEvent("GET", "/hello"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(out.StatusCode, out.Headers["Content-Type"], out.MultiValueHeaders["Vary"])
+	fmt.Println(out.Body)
+	// Output:
+	// 200 text/plain [Accept Origin]
+	// GET /hello
+}
+
+func ExampleHandler_cookies() {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", HttpOnly: true})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark"})
+		w.WriteHeader(http.StatusNoContent)
+	})
+	out, err := apig.Handler(h)(context.Background(), newEvent("POST", "/login"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(out.StatusCode)
+	for _, c := range out.Cookies {
+		fmt.Println(c)
+	}
+	// Output:
+	// 204
+	// session=abc; HttpOnly
+	// theme=dark
+}
+
+func ExampleHandler_binary() {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.WriteString(w, "\x00\x01\x02\xff")
+	})
+	out, err := apig.Handler(h)(context.Background(), newEvent("GET", "/blob"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(out.StatusCode, out.IsBase64Encoded, out.Body)
+	// Output:
+	// 200 true AAEC/w==
+}
@@ -0,0 +1,57 @@
+package apig
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Fail makes the Lambda invocation serving request r fail with err once the
+// handler returns, instead of sending the response handler wrote, so that
+// Lambda retry and destination settings of asynchronous invocations apply.
+// Only the first non-nil error is kept. Fail does nothing if r was not
+// created by this package.
+//
+// Fail only applies to buffered responses; with StreamingHandler, where
+// response may be already sent by the time handler returns, it is ignored.
+func Fail(r *http.Request, err error) {
+	if m := Values(r.Context()); m != nil && err != nil {
+		m.LoadOrStore(failKey{}, err)
+	}
+}
+
+// failKey is the key of Values map under which Fail stores the error.
+type failKey struct{}
+
+// failure returns error recorded by Fail for the request with context ctx.
+func failure(ctx context.Context) error {
+	if m := Values(ctx); m != nil {
+		if err, ok := m.Load(failKey{}); ok {
+			return err.(error)
+		}
+	}
+	return nil
+}
+
+// WithInvocationErrors configures handler to fail the Lambda invocation,
+// instead of sending the response, if fn reports true for the response
+// status code, as if handler called Fail. This treats all 5xx responses,
+// including ones sent on panics, as invocation errors:
+//
+//	apig.WithInvocationErrors(func(code int) bool { return code >= 500 })
+//
+// Responses failing invocation are not saved for WithIdempotency. The option
+// only applies to buffered responses, see Fail.
+func WithInvocationErrors(fn func(status int) bool) Option {
+	return func(c *config) { c.failStatus = fn }
+}
+
+// statusError returns invocation error for response res served for request
+// r, if it should fail invocation according to WithInvocationErrors.
+func (h *lambdaHandler) statusError(r *http.Request, res *response) error {
+	if h.failStatus == nil || res.err != nil || !h.failStatus(res.status) {
+		return nil
+	}
+	return fmt.Errorf("apig: %s %s: handler responded with %d %s status",
+		r.Method, r.URL.Path, res.status, http.StatusText(res.status))
+}
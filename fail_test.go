@@ -0,0 +1,82 @@
+package apig
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestFail(t *testing.T) {
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			Fail(r, nil)
+			Fail(r, errFirst)
+			Fail(r, errSecond)
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+		io.WriteString(w, "body")
+	})
+	failServerErrors := WithInvocationErrors(func(code int) bool { return code >= 500 })
+	for _, tc := range []struct {
+		name    string
+		path    string
+		opts    []Option
+		wantErr error // compared with errors.Is if not nil
+		fail    bool
+		code    int
+	}{
+		{"first error kept", "/fail", nil, errFirst, true, 0},
+		{"ok", "/", []Option{failServerErrors}, nil, false, http.StatusOK},
+		{"client error", "/missing", []Option{failServerErrors}, nil, false, http.StatusNotFound},
+		{"server error", "/unavailable", []Option{failServerErrors}, nil, true, 0},
+		{"server error default", "/unavailable", nil, nil, false, http.StatusServiceUnavailable},
+	} {
+		store := new(mapStore)
+		evt := testEvent("POST", tc.path)
+		evt.Headers["idempotency-key"] = "k1"
+		out, err := Handler(h, append(tc.opts, WithIdempotency(store, 0))...)(context.Background(), evt)
+		if tc.fail {
+			if err == nil || (tc.wantErr != nil && !errors.Is(err, tc.wantErr)) {
+				t.Errorf("%s: got error %v, want %v", tc.name, err, tc.wantErr)
+			}
+			if len(store.m) != 0 {
+				t.Errorf("%s: failed response was saved for idempotency key", tc.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		if out.StatusCode != tc.code || out.Body != "body" {
+			t.Errorf("%s: got %d %q, want %d", tc.name, out.StatusCode, out.Body, tc.code)
+		}
+		if saved := len(store.m) != 0; saved != (tc.code < 500) {
+			t.Errorf("%s: got response saved for idempotency key: %v", tc.name, saved)
+		}
+	}
+
+	// Fail outside of a request served by this package is a no-op
+	r, _ := http.NewRequest("GET", "/", nil)
+	Fail(r, errFirst)
+	if err := failure(r.Context()); err != nil {
+		t.Errorf("got failure %v for request not created by handler", err)
+	}
+}
+
+func TestInvocationErrorsPanic(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("got panic value %v, want %q", v, "boom")
+		}
+	}()
+	Handler(h, WithInvocationErrors(func(int) bool { return true }))(context.Background(), testEvent("GET", "/"))
+}
@@ -0,0 +1,40 @@
+package apig
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ServeBytes returns a handler replying to every request with content, using
+// http.ServeContent: content type is detected from name extension or sniffed
+// from content, and conditional and range requests are handled. If modtime is
+// not zero, it is sent as Last-Modified header. Binary content is
+// base64-encoded in the Lambda response as usual.
+func ServeBytes(name string, modtime time.Time, content []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, name, modtime, bytes.NewReader(content))
+	})
+}
+
+// ServeReader returns a handler replying to every request with content
+// provided by the open function, which is called for each request; reader it
+// returns is closed once the request is served. It is intended to be used
+// with StreamingHandler, so that content is streamed as it is read, and
+// otherwise works the same as ServeBytes. If open fails, handler responds with
+// 500 Internal Server Error.
+func ServeReader(name string, modtime time.Time, open func() (io.ReadSeekCloser, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rs, err := open()
+		if err != nil {
+			if c, _ := r.Context().Value(configKey).(*config); c != nil {
+				c.logf("apig: %s %s: opening %s: %v", r.Method, r.URL.Path, name, err)
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		defer rs.Close()
+		http.ServeContent(w, r, name, modtime, rs)
+	})
+}
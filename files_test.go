@@ -0,0 +1,83 @@
+package apig
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readSeekNopCloser adds no-op Close method to *strings.Reader, recording
+// whether it was called.
+type readSeekNopCloser struct {
+	*strings.Reader
+	closed bool
+}
+
+func (r *readSeekNopCloser) Close() error { r.closed = true; return nil }
+
+func TestServeBytes(t *testing.T) {
+	modtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tc := range []struct {
+		name, file, content string
+		headers             map[string]string
+		wantCode            int
+		wantType, wantBody  string
+	}{
+		{"html", "index.html", "<p>hi</p>", nil, http.StatusOK, "text/html; charset=utf-8", "<p>hi</p>"},
+		{"sniffed", "data", "\x89PNG\r\n\x1a\n", nil, http.StatusOK, "image/png", "\x89PNG\r\n\x1a\n"},
+		{"range", "a.txt", "0123456789", map[string]string{"range": "bytes=2-4"},
+			http.StatusPartialContent, "text/plain; charset=utf-8", "234"},
+		{"not modified", "a.txt", "0123456789", map[string]string{"if-modified-since": modtime.Format(http.TimeFormat)},
+			http.StatusNotModified, "", ""},
+	} {
+		evt := testEvent("GET", "/")
+		for k, v := range tc.headers {
+			evt.Headers[k] = v
+		}
+		out := invoke(t, ServeBytes(tc.file, modtime, []byte(tc.content)), evt)
+		body := out.Body
+		if out.IsBase64Encoded {
+			b, err := base64.StdEncoding.DecodeString(body)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			body = string(b)
+		}
+		if out.StatusCode != tc.wantCode || out.Headers["Content-Type"] != tc.wantType || body != tc.wantBody {
+			t.Errorf("%s: got %d %q with Content-Type %q, want %d %q with %q", tc.name,
+				out.StatusCode, body, out.Headers["Content-Type"], tc.wantCode, tc.wantBody, tc.wantType)
+		}
+		if tc.wantCode != http.StatusNotModified && out.Headers["Last-Modified"] != modtime.Format(http.TimeFormat) {
+			t.Errorf("%s: got Last-Modified %q", tc.name, out.Headers["Last-Modified"])
+		}
+	}
+}
+
+func TestServeReader(t *testing.T) {
+	var rs *readSeekNopCloser
+	open := func() (io.ReadSeekCloser, error) {
+		rs = &readSeekNopCloser{Reader: strings.NewReader("streamed content")}
+		return rs, nil
+	}
+	out, err := StreamingHandler(ServeReader("a.txt", time.Time{}, open))(context.Background(), testEvent("GET", "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(out.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.StatusCode != http.StatusOK || string(b) != "streamed content" || !rs.closed {
+		t.Errorf("got %d %q, reader closed: %v", out.StatusCode, b, rs.closed)
+	}
+
+	failing := func() (io.ReadSeekCloser, error) { return nil, errors.New("no such object") }
+	if out := invoke(t, ServeReader("a.txt", time.Time{}, failing), testEvent("GET", "/")); out.StatusCode != http.StatusInternalServerError {
+		t.Errorf("failing open: got status %d, want 500", out.StatusCode)
+	}
+}
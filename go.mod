@@ -1,5 +1,8 @@
 module github.com/artyom/apig
 
-go 1.18
+go 1.22
 
-require github.com/aws/aws-lambda-go v1.27.1
+require (
+	github.com/aws/aws-lambda-go v1.47.0
+	golang.org/x/text v0.14.0
+)
@@ -1,6 +1,8 @@
 // Package apig provides an adapter enabling use of http.Handler inside AWS
 // Lambda running as AWS API Gateway HTTP API target. It also supports Lambda
-// Function URLs.
+// Function URLs (StreamingHandler supports response streaming mode),
+// Application Load Balancer targets with ALBHandler, API Gateway REST APIs
+// with HandlerV1, and API Gateway WebSocket APIs with WebSocketHandler.
 //
 // For more context see
 // https://docs.aws.amazon.com/apigateway/latest/developerguide/http-api.html
@@ -29,12 +31,17 @@ package apig
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"io"
 	"net/http"
-	"net/http/httptest"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unicode/utf8"
 
 	"github.com/aws/aws-lambda-go/events"
@@ -43,58 +50,564 @@ import (
 // Handler returns function suitable to use as an AWS Lambda handler with
 // github.com/aws/aws-lambda-go/lambda package.
 //
-// Note that both request and response are fully cached in memory.
-func Handler(h http.Handler) func(context.Context, *events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTPResponse, error) {
+// Query string of the event is used as r.URL.RawQuery verbatim, without any
+// decoding or re-encoding, so r.URL.Query decodes it exactly once.
+// Event RawPath is used as r.URL.Path; an empty path, which Function URLs
+// may deliver for the root, becomes "/". Client IP address reported in the
+// event is used as r.RemoteAddr, without a port.
+// Function URLs and API Gateway only accept HTTPS connections, so r.TLS is
+// always set, and r.Proto reports protocol version client used, if the event
+// has it. r.RequestURI is set from the event raw path and query string as
+// they were delivered, as net/http server does with the request line.
+// Path parameters of the API Gateway route, such as id of the
+// "GET /users/{id}" route, are available with r.PathValue; note that
+// http.ServeMux replaces them with values matched by its own patterns.
+//
+// Response is converted to the API Gateway format as follows: Set-Cookie
+// headers are moved to the Cookies field, headers with multiple values are
+// put into MultiValueHeaders, other headers go to Headers. Body that is not
+// a valid UTF-8, or has a Content-Encoding or binary Content-Type (image,
+// audio, video, font, application/octet-stream and the like, see also
+// WithBinaryContentTypes), is base64-encoded, and IsBase64Encoded is set.
+//
+// Response trailers, if handler sets any, are sent as regular headers.
+// Responses with a body always have Content-Type: if handler suppresses
+// content type detection by setting an empty value, the type is detected
+// with http.DetectContentType anyway.
+// http.ResponseWriter passed to the handler implements io.ReaderFrom, and
+// http.Flusher which does nothing, as response is only sent once handler
+// returns; informational 1xx responses other than 101 are ignored, and writes
+// past the Content-Length handler set fail with http.ErrContentLength, as
+// with net/http server. It does not implement
+// http.Hijacker, since there is no connection to take over. For the same
+// reason Connection request header, and headers it lists, are removed, and
+// protocol upgrades cannot work: if handler responds with 101 Switching
+// Protocols, the response is replaced with 500 Internal Server Error, and the
+// attempt is logged.
+//
+// Note that both request and response are fully cached in memory. Because of
+// that, Expect header is removed from requests: "100-continue" expectation
+// cannot be honored, since request body has already been received.
+// Base64-encoded request body is decoded as handler reads it, so handlers
+// ignoring the body do not pay for decoding. Because of that, malformed
+// base64 input does not fail the invocation: it is reported as an error
+// from r.Body.Read instead, once handler reaches the malformed part.
+func Handler(h http.Handler, opts ...Option) func(context.Context, *events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTPResponse, error) {
 	if h == nil {
 		panic("Handler called with nil argument")
 	}
+	return newHandler(h, opts).Run
+}
+
+func newHandler(h http.Handler, opts []Option) *lambdaHandler {
 	hh := &lambdaHandler{handler: h}
-	return hh.Run
+	for _, opt := range opts {
+		opt(&hh.config)
+	}
+	if hh.maxConcurrency > 0 {
+		hh.sem = make(chan struct{}, hh.maxConcurrency)
+	}
+	if hh.basePath != "" {
+		hh.handler = &basePathHandler{next: h, prefix: hh.basePath}
+	}
+	return hh
 }
 
 type lambdaHandler struct {
 	handler http.Handler
+	config
+	sem chan struct{} // limits concurrency, see WithMaxConcurrency
 }
 
 func (h *lambdaHandler) Run(ctx context.Context, req *events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTPResponse, error) {
-	headers := make(http.Header, len(req.Headers))
-	for k, v := range req.Headers {
-		headers.Set(k, v)
+	atomic.AddUint64(&invocations, 1)
+	r, res, err := h.newRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = h.serve(r)
+	}
+	defer res.release()
+	if res.err != nil {
+		return nil, res.err
 	}
-	if len(req.Cookies) != 0 {
+	out := h.responseV2(res)
+	if len(h.responseEventInterceptors) != 0 {
+		h.interceptResponseEvent(ctx, out)
+	}
+	return out, nil
+}
+
+// newRequest converts API Gateway HTTP API event to http.Request. If request
+// cannot be created, it returns response that should be sent instead.
+func (h *lambdaHandler) newRequest(ctx context.Context, req *events.APIGatewayV2HTTPRequest) (*http.Request, *response, error) {
+	if len(h.requestInterceptors) != 0 {
+		if res, err := h.interceptEvent(ctx, req); res != nil || err != nil {
+			return nil, res, err
+		}
+	}
+	method, ok := normalizeMethod(req.RequestContext.HTTP.Method, h.extraMethods)
+	if !ok {
+		return nil, errorResponse(http.StatusBadRequest), nil
+	}
+	headers := singleValueHeaders(req.Headers)
+	if len(req.Cookies) != 0 && !h.rawCookies {
 		headers[http.CanonicalHeaderKey("Cookie")] = req.Cookies
 	}
 	r := &http.Request{
 		ProtoMajor: 1,
 		ProtoMinor: 1,
 		Proto:      "HTTP/1.1",
-		Method:     req.RequestContext.HTTP.Method,
-		URL:        &url.URL{Path: req.RawPath, RawQuery: req.RawQueryString},
+		Method:     method,
+		URL:        &url.URL{Path: h.requestPath(method, req.RawPath), RawQuery: req.RawQueryString},
 		Header:     headers,
-		Host:       headers.Get("Host"),
+		Host:       h.host(headers, req.RequestContext.DomainName),
+		RemoteAddr: req.RequestContext.HTTP.SourceIP,
+	}
+	h.setProto(r, req.RequestContext.HTTP.Protocol)
+	setConn(r, true)
+	// r.URL.Path holds the raw path, which r.URL.RequestURI would escape
+	// once more, and may have base path stripped
+	r.RequestURI = req.RawPath
+	if r.RequestURI == "" {
+		r.RequestURI = "/"
+	}
+	if req.RawQueryString != "" {
+		r.RequestURI += "?" + req.RawQueryString
+	}
+	ctx = context.WithValue(ctx, eventKey, req)
+	ctx = h.requestContext(ctx, req.RequestContext.RequestID, headers)
+	if h.contextFunc != nil {
+		ctx = h.contextFunc(ctx, req)
 	}
 	r = r.WithContext(ctx)
-	switch {
-	case req.IsBase64Encoded:
-		b, err := base64.StdEncoding.DecodeString(req.Body)
-		if err != nil {
-			return nil, err
-		}
-		r.Body = io.NopCloser(bytes.NewReader(b))
-		r.ContentLength = int64(len(b))
-	default:
-		r.Body = io.NopCloser(strings.NewReader(req.Body))
-		r.ContentLength = int64(len(req.Body))
-	}
-	recorder := httptest.NewRecorder()
-	h.handler.ServeHTTP(recorder, r)
-	res := recorder.Result()
+	setPathValues(r, req.PathParameters)
+	h.setBody(r, req.Body, req.IsBase64Encoded)
+	return r, nil, nil
+}
+
+// response is a buffered handler response, independent of the Lambda event
+// format it is later converted to.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+	base64 *bool // if set, overrides whether body is base64-encoded
+	err    error // if set, invocation fails with it instead of responding
+
+	buf *bytes.Buffer // pooled buffer body may point to, see release
+}
+
+// release returns buffer of res to the pool. It must be called once res is
+// converted to the Lambda response, after which res cannot be used.
+func (res *response) release() {
+	if res.buf != nil {
+		putBuffer(res.buf)
+		res.buf, res.body = nil, nil
+	}
+}
+
+// Base64Header is a response header that handler can set to "true" or
+// "false" to explicitly control whether response body is base64-encoded in
+// the Lambda response, instead of relying on the automatic detection. This
+// header is removed from the response.
+const Base64Header = "X-Apig-Base64"
+
+// serve calls handler with request r and returns its buffered response.
+func (h *lambdaHandler) serve(r *http.Request) (out *response) {
+	if h.failStatus != nil {
+		defer func() {
+			if out == nil {
+				return // handler panic is not recovered
+			}
+			if err := h.statusError(r, out); err != nil {
+				out.release()
+				out = &response{err: err}
+			}
+		}()
+	}
+	if h.timed() {
+		start := time.Now()
+		defer func() {
+			// out is nil if handler panicked and panics are not
+			// recovered, leave such panic to propagate
+			if out != nil {
+				h.served(r, out.status, len(out.body), time.Since(start))
+			}
+		}()
+	}
+	if res := h.precheck(r); res != nil {
+		return res
+	}
+	var storeKey string
+	if h.idempotencyStore != nil {
+		if key, ok := idempotencyKey(r); ok {
+			if res := h.storedResponse(r, key); res != nil {
+				return h.finishResponse(r, res)
+			}
+			storeKey = key
+		}
+	}
+	if res := h.acquire(); res != nil {
+		return res
+	}
+	defer h.release()
+	if h.debugDump != nil {
+		h.dumpRequest(r)
+	}
+	buf := bufPool.Get().(*bytes.Buffer)
+	defer func() {
+		// response owns the buffer and releases it once it is
+		// converted, unless the response was replaced
+		if out == nil || out.buf != buf {
+			putBuffer(buf)
+		}
+	}()
+	w := newResponseWriter(buf, h.defaultContentType)
+	if h.recovers() {
+		if res := h.serveRecover(w, r); res != nil {
+			return res
+		}
+	} else {
+		h.handler.ServeHTTP(w, r)
+	}
+	if err := failure(r.Context()); err != nil {
+		return &response{err: err}
+	}
+	status, header, trailer := w.result()
+	if status == http.StatusSwitchingProtocols {
+		h.logf("apig: %s %s: handler attempted a protocol upgrade (Upgrade: %q),"+
+			" which buffered responses cannot deliver; use StreamingHandler for"+
+			" streamed responses, or WebSocketHandler for WebSocket APIs",
+			r.Method, r.URL.Path, header.Get("Upgrade"))
+		return errorResponse(http.StatusInternalServerError)
+	}
+	var body []byte
+	if buf.Len() != 0 {
+		body = buf.Bytes()
+	}
+	if cl := header.Get("Content-Length"); cl != "" && cl != strconv.Itoa(len(body)) &&
+		r.Method != http.MethodHead && status != http.StatusNotModified {
+		h.logf("apig: %s %s: handler set Content-Length %s, but wrote %d bytes; correcting header",
+			r.Method, r.URL.Path, cl, len(body))
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	// response is fully buffered, so chunked (or any other) transfer coding
+	// makes no sense here
+	header.Del("Transfer-Encoding")
+	// Lambda responses cannot carry trailers, so they become regular headers
+	if len(trailer) != 0 {
+		header.Del("Trailer")
+		for k, vv := range trailer {
+			header[k] = append(header[k], vv...)
+		}
+	}
+	// handler may suppress content type sniffing by setting an empty
+	// Content-Type, but without it API Gateway may mislabel the response
+	if len(body) != 0 && header.Get("Content-Type") == "" && r.Method != http.MethodHead {
+		ct := h.defaultContentType
+		if ct == "" {
+			ct = http.DetectContentType(body)
+		}
+		header.Set("Content-Type", ct)
+	}
+	out = &response{status: status, header: header, body: body, buf: buf}
+	if v := header.Get(Base64Header); v != "" {
+		header.Del(Base64Header)
+		if b, err := strconv.ParseBool(v); err == nil {
+			out.base64 = &b
+		} else {
+			h.logf("apig: %s %s: invalid %s header value %q", r.Method, r.URL.Path, Base64Header, v)
+		}
+	}
+	if h.validateCookies || h.maxCookieBytes > 0 {
+		h.filterCookies(r, out)
+	}
+	if storeKey != "" {
+		// saved before the steps below, as their results depend on the
+		// request and are not to be replayed
+		saved := storedCopy(out)
+		defer func() { h.storeResponse(r, storeKey, saved, out) }()
+	}
+	return h.finishResponse(r, out)
+}
+
+// finishResponse applies to response out the steps that depend on request r,
+// such as content encoding, and the response limits. Responses saved for
+// idempotency keys are replayed through it too.
+func (h *lambdaHandler) finishResponse(r *http.Request, out *response) *response {
+	if len(h.reflectHeaders) != 0 {
+		h.reflectRequestHeaders(r, out.header)
+	}
+	if h.traceHeader {
+		setTraceHeader(r, out.header)
+	}
+	if h.dateHeader && out.header.Get("Date") == "" {
+		out.header.Set("Date", h.now().UTC().Format(http.TimeFormat))
+	}
+	if h.conditional {
+		checkNotModified(r, out)
+	}
+	if h.ranges {
+		serveRange(r, out)
+	}
+	if fn := h.errorPages[out.status]; fn != nil && len(out.body) == 0 && r.Method != http.MethodHead {
+		ct, body := fn(r)
+		out.body = body
+		out.header.Del("Content-Length")
+		if ct != "" {
+			out.header.Set("Content-Type", ct)
+		}
+	}
+	if len(h.interceptors) != 0 {
+		h.intercept(r, out)
+	}
+	if h.compress && r.Method != http.MethodHead {
+		h.compressResponse(r, out)
+	}
+	if h.responseTransform != nil {
+		h.transformResponse(r, out)
+	}
+	if h.maxRespHeaderBytes > 0 {
+		var size int
+		for k, vv := range out.header {
+			for _, v := range vv {
+				size += len(k) + len(v)
+			}
+		}
+		if size > h.maxRespHeaderBytes {
+			h.logf("apig: %s %s: response headers size %d exceeds limit of %d bytes",
+				r.Method, r.URL.Path, size, h.maxRespHeaderBytes)
+			out = errorResponse(http.StatusInternalServerError)
+		}
+	}
+	if h.maxResponseSize > 0 {
+		if out = h.limitResponseSize(r, out); out.err != nil {
+			return out
+		}
+	}
+	if h.debugDump != nil {
+		h.dumpResponse(r, out)
+	}
+	return out
+}
+
+// bufPool holds buffers for response bodies, so that buffers grown by
+// previous responses are reused.
+var bufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// putBuffer returns buf to bufPool, unless it has grown too large to keep.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > MaxPayloadSize {
+		return
+	}
+	buf.Reset()
+	bufPool.Put(buf)
+}
+
+// precheck prepares request r to be passed to the handler. If the request
+// should not reach the handler, it returns response to send instead.
+func (h *lambdaHandler) precheck(r *http.Request) *response {
+	// there is no connection to send an interim 100 Continue response to,
+	// and the whole body is already there
+	r.Header.Del("Expect")
+	// connection management is done by API Gateway, so Connection header
+	// and hop-by-hop headers it lists have no meaning for the handler
+	for _, v := range r.Header.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				r.Header.Del(name)
+			}
+		}
+	}
+	r.Header.Del("Connection")
+	if h.trimHeaders {
+		for _, vv := range r.Header {
+			for i, v := range vv {
+				vv[i] = strings.TrimSpace(v)
+			}
+		}
+		r.Host = strings.TrimSpace(r.Host)
+	}
+	if h.unescapePath && !h.unescapeRequestPath(r) {
+		return errorResponse(http.StatusBadRequest)
+	}
+	if len(h.allowedMethods) != 0 {
+		if res := h.methodNotAllowed(r); res != nil {
+			return res
+		}
+	}
+	if h.maxHeaderCount > 0 || h.maxHeaderBytes > 0 {
+		var count, size int
+		for k, vv := range r.Header {
+			for _, v := range vv {
+				count++
+				size += len(k) + len(v)
+			}
+		}
+		if (h.maxHeaderCount > 0 && count > h.maxHeaderCount) ||
+			(h.maxHeaderBytes > 0 && size > h.maxHeaderBytes) {
+			return errorResponse(http.StatusRequestHeaderFieldsTooLarge)
+		}
+	}
+	if h.cookiesTooLarge(r) {
+		return errorResponse(http.StatusRequestHeaderFieldsTooLarge)
+	}
+	if h.rejectGetBody && r.ContentLength > 0 &&
+		(r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		return errorResponse(http.StatusBadRequest)
+	}
+	if h.maxRequestSize > 0 && r.ContentLength > h.maxRequestSize {
+		return h.requestTooLarge(r)
+	}
+	if h.healthPath != "" && r.URL.Path == h.healthPath {
+		return &response{status: h.healthStatus, header: make(http.Header)}
+	}
+	if h.shutdown != nil {
+		select {
+		case <-h.shutdown:
+			res := errorResponse(http.StatusServiceUnavailable)
+			res.header.Set("Retry-After", "1")
+			return res
+		default:
+		}
+	}
+	if h.requestTransform != nil {
+		h.transformRequest(r)
+	}
+	if h.parseQueryForm && r.Form == nil {
+		r.Form = r.URL.Query()
+	}
+	// done last, so that everything above only sees canonical keys
+	if h.preserveHeaderCase {
+		addRawHeaderKeys(r)
+	}
+	return nil
+}
+
+// setBody sets r.Body and r.ContentLength from the event body, decoding it if
+// it is base64-encoded.
+func (h *lambdaHandler) setBody(r *http.Request, body string, isBase64 bool) {
+	if isBase64 {
+		// decode lazily, so handlers ignoring the body don't pay for it;
+		// malformed input is reported by r.Body.Read
+		r.Body = io.NopCloser(base64.NewDecoder(base64.StdEncoding, strings.NewReader(body)))
+		r.ContentLength = bodySize(body, true)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); ct != "" && !isTextContentType(ct) &&
+		(!utf8.ValidString(body) || strings.ContainsRune(body, utf8.RuneError)) {
+		h.logf("apig: %s %s: non-base64 %q request body looks like binary data"+
+			" that may be corrupted; consider adding this type to API Gateway binary media types",
+			r.Method, r.URL.Path, ct)
+	}
+	// body is passed as is, byte for byte, even if it is not a valid UTF-8:
+	// do not sanitize it
+	r.Body = io.NopCloser(strings.NewReader(body))
+	r.ContentLength = int64(len(body))
+}
+
+// requestContext returns ctx with values common to requests of all kinds
+// attached. Argument eventID is the request ID delivered in the event, if
+// any, headers are the request headers.
+func (h *lambdaHandler) requestContext(ctx context.Context, eventID string, headers http.Header) context.Context {
+	ctx = context.WithValue(ctx, configKey, &h.config)
+	ctx = context.WithValue(ctx, valuesKey, new(sync.Map))
+	ctx = withTrace(ctx, headers)
+	return h.withRequestID(ctx, eventID)
+}
+
+// requestPath returns path of http.Request for the path p delivered in the
+// event. Function URLs may deliver the root path as either "/" or "", so
+// result is normalized to always start with "/"; this is done as the last
+// step, after any other transformation of the path, so the root path is
+// always "/". Prefix configured with WithBasePath is removed first. Path "*"
+// of OPTIONS requests, which asks about the server as a whole, is kept as is.
+func (h *lambdaHandler) requestPath(method, p string) string {
+	if p == "*" && method == http.MethodOptions {
+		return p
+	}
+	if h.basePath != "" && hasPathPrefix(p, h.basePath) {
+		p = p[len(h.basePath):]
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return p
+}
+
+// hasPathPrefix reports whether path p starts with prefix matched by whole
+// path segments.
+func hasPathPrefix(p, prefix string) bool {
+	return strings.HasPrefix(p, prefix) && (len(p) == len(prefix) || p[len(prefix)] == '/')
+}
+
+// basePathHandler serves requests with paths delivered under the prefix
+// configured with WithBasePath, and the not found response to others.
+type basePathHandler struct {
+	next   http.Handler
+	prefix string
+}
+
+func (h *basePathHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if p := OriginalPath(r.Context()); hasPathPrefix(p, h.prefix) ||
+		(p == "*" && r.Method == http.MethodOptions) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	notFound(w, r)
+}
+
+// setPathValues makes path parameters API Gateway extracted from the
+// request path according to the route available with r.PathValue.
+func setPathValues(r *http.Request, params map[string]string) {
+	for k, v := range params {
+		r.SetPathValue(k, v)
+	}
+}
+
+// setConn fills fields of r that net/http server sets from the connection
+// and the request line. If secure is true, client is known to have connected
+// over TLS; details of the connection are not known, so r.TLS only reports
+// the completed handshake and the server name, which is the request host.
+func setConn(r *http.Request, secure bool) {
+	r.RequestURI = r.URL.RequestURI()
+	if secure {
+		r.TLS = &tls.ConnectionState{HandshakeComplete: true, ServerName: r.Host}
+	}
+}
+
+// singleValueHeaders converts event headers to http.Header. Keys differing
+// only in case are merged into a single header with multiple values, in no
+// particular order.
+func singleValueHeaders(src map[string]string) http.Header {
+	headers := make(http.Header, len(src)+1)
+	// single backing array for all header values saves an allocation per
+	// header, as done by net/textproto
+	values := make([]string, 0, len(src))
+	for k, v := range src {
+		ck := http.CanonicalHeaderKey(k)
+		if vv, ok := headers[ck]; ok {
+			// capacity of vv is limited, so append copies it
+			headers[ck] = append(vv, v)
+			continue
+		}
+		values = append(values, v)
+		headers[ck] = values[len(values)-1 : len(values) : len(values)]
+	}
+	return headers
+}
+
+// responseV2 converts res to the API Gateway HTTP API response.
+func (h *lambdaHandler) responseV2(res *response) *events.APIGatewayV2HTTPResponse {
 	out := &events.APIGatewayV2HTTPResponse{
-		StatusCode: res.StatusCode,
-		Headers:    make(map[string]string),
+		StatusCode: res.status,
+		Headers:    make(map[string]string, len(res.header)),
 	}
-	for k, vv := range res.Header {
-		if strings.EqualFold(k, "Set-Cookie") {
+	for _, k := range sortedKeys(res.header) {
+		vv := res.header[k]
+		if !h.rawCookies && strings.EqualFold(k, "Set-Cookie") {
 			out.Cookies = append(out.Cookies, vv...)
 			continue
 		}
@@ -107,11 +620,76 @@ func (h *lambdaHandler) Run(ctx context.Context, req *events.APIGatewayV2HTTPReq
 		}
 		out.MultiValueHeaders[k] = append(out.MultiValueHeaders[k], vv...)
 	}
-	if b := recorder.Body.Bytes(); utf8.Valid(b) {
-		out.Body = string(b)
-	} else {
-		out.Body = base64.StdEncoding.EncodeToString(b)
-		out.IsBase64Encoded = true
+	out.Body, out.IsBase64Encoded = h.encodeBody(res)
+	return out
+}
+
+// sortedKeys returns header keys in sorted order, so that the result of
+// conversion does not depend on the map iteration order. Order of values
+// within each key is preserved by conversion.
+func sortedKeys(header http.Header) []string {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// encodeBody returns body of res as a string suitable for the Lambda
+// response, reporting whether it had to be base64-encoded.
+func (h *lambdaHandler) encodeBody(res *response) (string, bool) {
+	encode, invalid := h.base64Body(res)
+	if !encode {
+		return string(res.body), false
+	}
+	if ct := res.header.Get("Content-Type"); invalid && isTextContentType(ct) {
+		// usually a sign of corrupted output
+		h.logf("apig: response body of %q type is not a valid UTF-8, sending it base64-encoded", ct)
+	}
+	return h.base64Enc().EncodeToString(res.body), true
+}
+
+// base64Body reports whether body of res has to be base64-encoded, and
+// whether this is because it is not a valid UTF-8.
+func (h *lambdaHandler) base64Body(res *response) (encode, invalidUTF8 bool) {
+	if len(res.body) == 0 {
+		// handler may only call WriteHeader, empty body is never
+		// base64-encoded
+		return false, false
+	}
+	if res.base64 != nil {
+		return *res.base64, false
+	}
+	switch h.base64Mode {
+	case Base64Always:
+		return true, false
+	case Base64Never:
+		return false, false
+	}
+	// skip scanning the whole body when headers already tell it's binary
+	if res.header.Get("Content-Encoding") != "" || h.binaryResponse(res.header.Get("Content-Type")) {
+		return true, false
+	}
+	if h.base64Mode == Base64ByContentType || utf8.Valid(res.body) {
+		return false, false
+	}
+	return true, true
+}
+
+// base64Enc returns encoding used for base64-encoded response bodies.
+func (c *config) base64Enc() *base64.Encoding {
+	if c.base64Encoding != nil {
+		return c.base64Encoding
+	}
+	return base64.StdEncoding
+}
+
+// errorResponse returns a plain text response with the given status code.
+func errorResponse(code int) *response {
+	return &response{
+		status: code,
+		header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+		body:   []byte(http.StatusText(code) + "\n"),
 	}
-	return out, nil
 }
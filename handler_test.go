@@ -0,0 +1,1223 @@
+package apig
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// testEvent returns a minimal Function URL event for the request with the
+// given method and raw path, which may include query.
+func testEvent(method, target string) *events.APIGatewayV2HTTPRequest {
+	path, query, _ := strings.Cut(target, "?")
+	evt := &events.APIGatewayV2HTTPRequest{
+		Version:        "2.0",
+		RouteKey:       "$default",
+		RawPath:        path,
+		RawQueryString: query,
+		Headers:        map[string]string{"host": "example.lambda-url.us-east-1.on.aws"},
+	}
+	evt.RequestContext.DomainName = "example.lambda-url.us-east-1.on.aws"
+	evt.RequestContext.HTTP.Method = method
+	evt.RequestContext.HTTP.Path = path
+	evt.RequestContext.HTTP.Protocol = "HTTP/1.1"
+	evt.RequestContext.HTTP.SourceIP = "192.0.2.1"
+	return evt
+}
+
+// invoke serves evt with h wrapped by Handler with opts, failing the test on
+// invocation error.
+func invoke(t testing.TB, h http.Handler, evt *events.APIGatewayV2HTTPRequest, opts ...Option) *events.APIGatewayV2HTTPResponse {
+	t.Helper()
+	out, err := Handler(h, opts...)(context.Background(), evt)
+	if err != nil {
+		t.Fatalf("%s %s: %v", evt.RequestContext.HTTP.Method, evt.RawPath, err)
+	}
+	return out
+}
+
+type tenantKey struct{}
+
+func TestContextFunc(t *testing.T) {
+	var got any
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.Context().Value(tenantKey{}) })
+	fn := func(ctx context.Context, evt *events.APIGatewayV2HTTPRequest) context.Context {
+		tenant, _, _ := strings.Cut(evt.Headers["host"], ".")
+		return context.WithValue(ctx, tenantKey{}, tenant)
+	}
+	invoke(t, h, testEvent("GET", "/"), WithContextFunc(fn))
+	if got != "example" {
+		t.Errorf("got context value %v, want %q", got, "example")
+	}
+}
+
+func TestRequestMethod(t *testing.T) {
+	for _, tc := range []struct {
+		method   string
+		opts     []Option
+		want     string
+		wantCode int
+	}{
+		{"GET", nil, "GET", http.StatusOK},
+		{"get", nil, "GET", http.StatusOK},
+		{"Delete", nil, "DELETE", http.StatusOK},
+		{"PROPFIND", nil, "PROPFIND", http.StatusOK},
+		{"propfind", nil, "propfind", http.StatusOK},
+		{"propfind", []Option{WithExtensionMethods("PROPFIND")}, "PROPFIND", http.StatusOK},
+		{"", nil, "", http.StatusBadRequest},
+		{"GET /", nil, "", http.StatusBadRequest},
+		{"G\x00T", nil, "", http.StatusBadRequest},
+		{"GÉT", nil, "", http.StatusBadRequest},
+	} {
+		var got string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.Method })
+		out := invoke(t, h, testEvent(tc.method, "/"), tc.opts...)
+		if out.StatusCode != tc.wantCode {
+			t.Errorf("method %q: got status %d, want %d", tc.method, out.StatusCode, tc.wantCode)
+		}
+		if got != tc.want {
+			t.Errorf("method %q: handler got method %q, want %q", tc.method, got, tc.want)
+		}
+	}
+}
+
+func TestTransferEncodingStripped(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Transfer-Encoding", "chunked")
+		io.WriteString(w, "hello")
+	})
+	out := invoke(t, h, testEvent("GET", "/"))
+	if v, ok := out.Headers["Transfer-Encoding"]; ok {
+		t.Errorf("got Transfer-Encoding %q, want none", v)
+	}
+	if out.Body != "hello" {
+		t.Errorf("got body %q, want %q", out.Body, "hello")
+	}
+}
+
+func TestUnencodedBinaryRequestBody(t *testing.T) {
+	for _, body := range []string{"\xff\xfe\x00\x01", "aGVsbG8="} {
+		var got []byte
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got, _ = io.ReadAll(r.Body) })
+		evt := testEvent("POST", "/")
+		evt.Headers["content-type"] = "application/octet-stream"
+		evt.Body = body
+		invoke(t, h, evt)
+		if string(got) != body {
+			t.Errorf("got body %q, want %q passed as is", got, body)
+		}
+	}
+}
+
+func TestContentLengthMismatch(t *testing.T) {
+	for _, tc := range []struct {
+		name, contentLength, write string
+		wantBody, wantLength       string
+		wantErr                    error
+	}{
+		{"exact", "5", "hello", "hello", "5", nil},
+		{"short", "10", "hello", "hello", "5", nil},
+		{"long", "3", "hello", "hel", "3", http.ErrContentLength},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var err error
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", tc.contentLength)
+				_, err = io.WriteString(w, tc.write)
+			})
+			out := invoke(t, h, testEvent("GET", "/"))
+			if err != tc.wantErr {
+				t.Errorf("got write error %v, want %v", err, tc.wantErr)
+			}
+			if out.Body != tc.wantBody {
+				t.Errorf("got body %q, want %q", out.Body, tc.wantBody)
+			}
+			if got := out.Headers["Content-Length"]; got != tc.wantLength {
+				t.Errorf("got Content-Length %q, want %q", got, tc.wantLength)
+			}
+		})
+	}
+}
+
+// BenchmarkSmallJSON measures the common case of a small JSON response with
+// a few distinct headers.
+func BenchmarkSmallJSON(b *testing.B) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Request-Id", "1234")
+		io.WriteString(w, `{"id":42,"name":"example","tags":["a","b"]}`)
+	})
+	fn := Handler(h)
+	evt := testEvent("GET", "/items/42")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		out, err := fn(context.Background(), evt)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if out.MultiValueHeaders != nil || out.IsBase64Encoded {
+			b.Fatalf("got multi-value headers %v, base64 %v", out.MultiValueHeaders, out.IsBase64Encoded)
+		}
+	}
+}
+
+func TestExpectHeaderRemoved(t *testing.T) {
+	var expect []string
+	var body string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expect = r.Header.Values("Expect")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	})
+	evt := testEvent("PUT", "/upload")
+	evt.Headers["expect"] = "100-continue"
+	evt.Body = "payload"
+	invoke(t, h, evt)
+	if len(expect) != 0 {
+		t.Errorf("got Expect header %q, want none", expect)
+	}
+	if body != "payload" {
+		t.Errorf("got body %q, want %q", body, "payload")
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	var called bool
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		io.WriteString(w, "app")
+	})
+	for _, tc := range []struct {
+		path       string
+		wantCode   int
+		wantBody   string
+		wantCalled bool
+	}{
+		{"/healthz", http.StatusNoContent, "", false},
+		{"/healthz/", http.StatusOK, "app", true},
+		{"/healthzz", http.StatusOK, "app", true},
+		{"/", http.StatusOK, "app", true},
+	} {
+		called = false
+		out := invoke(t, h, testEvent("GET", tc.path), WithHealthCheck("/healthz", http.StatusNoContent))
+		if out.StatusCode != tc.wantCode || out.Body != tc.wantBody {
+			t.Errorf("%s: got %d %q, want %d %q", tc.path, out.StatusCode, out.Body, tc.wantCode, tc.wantBody)
+		}
+		if called != tc.wantCalled {
+			t.Errorf("%s: handler called: %v, want %v", tc.path, called, tc.wantCalled)
+		}
+	}
+}
+
+func TestEmptyBodyStatus(t *testing.T) {
+	for _, code := range []int{http.StatusCreated, http.StatusAccepted, http.StatusNoContent, http.StatusNotFound} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(code) })
+		out := invoke(t, h, testEvent("POST", "/"))
+		if out.StatusCode != code || out.Body != "" || out.IsBase64Encoded {
+			t.Errorf("status %d: got %d, body %q, base64 %v", code, out.StatusCode, out.Body, out.IsBase64Encoded)
+		}
+	}
+}
+
+func TestResponseHeaderOrder(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, v := range []string{"c", "a", "b"} {
+			w.Header().Add("X-Multi", v)
+		}
+		// non-canonical key set directly, as some middleware does
+		w.Header()["set-cookie"] = []string{"z=3"}
+		w.Header().Add("Set-Cookie", "y=2")
+		w.Header().Add("Set-Cookie", "x=1")
+	})
+	want := []string{"y=2", "x=1", "z=3"}
+	for i := 0; i < 20; i++ {
+		out := invoke(t, h, testEvent("GET", "/"))
+		if got := out.MultiValueHeaders["X-Multi"]; !reflect.DeepEqual(got, []string{"c", "a", "b"}) {
+			t.Fatalf("got X-Multi %q, want values in insertion order", got)
+		}
+		if !reflect.DeepEqual(out.Cookies, want) {
+			t.Fatalf("got cookies %q, want %q", out.Cookies, want)
+		}
+	}
+}
+
+func TestShutdownSignal(t *testing.T) {
+	var calls int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls++ })
+	shutdown := make(chan struct{})
+	fn := Handler(h, WithShutdownSignal(shutdown))
+	for i := 0; i < 3; i++ {
+		closed := i > 0
+		if i == 1 {
+			close(shutdown)
+		}
+		out, err := fn(context.Background(), testEvent("GET", "/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantCode, wantRetry := http.StatusOK, ""
+		if closed {
+			wantCode, wantRetry = http.StatusServiceUnavailable, "1"
+		}
+		if out.StatusCode != wantCode || out.Headers["Retry-After"] != wantRetry {
+			t.Errorf("request %d: got %d with Retry-After %q, want %d with %q",
+				i, out.StatusCode, out.Headers["Retry-After"], wantCode, wantRetry)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestBase64Header(t *testing.T) {
+	for _, tc := range []struct {
+		name, contentType, body, sentinel string
+		wantBase64                        bool
+	}{
+		{"auto text", "text/plain", "hello", "", false},
+		{"auto binary", "application/octet-stream", "\xff\x00", "", true},
+		{"force on", "text/plain", "hello", "true", true},
+		{"force off", "application/octet-stream", "hello", "false", false},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tc.contentType)
+			if tc.sentinel != "" {
+				w.Header().Set(Base64Header, tc.sentinel)
+			}
+			io.WriteString(w, tc.body)
+		})
+		out := invoke(t, h, testEvent("GET", "/"))
+		if out.IsBase64Encoded != tc.wantBase64 {
+			t.Errorf("%s: got base64 %v, want %v", tc.name, out.IsBase64Encoded, tc.wantBase64)
+		}
+		body := out.Body
+		if out.IsBase64Encoded {
+			b, err := base64.StdEncoding.DecodeString(body)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			body = string(b)
+		}
+		if body != tc.body {
+			t.Errorf("%s: got body %q, want %q", tc.name, body, tc.body)
+		}
+		if _, ok := out.Headers[Base64Header]; ok {
+			t.Errorf("%s: %s header leaked into response", tc.name, Base64Header)
+		}
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "report")
+	fw, err := mw.CreateFormFile("file", "data.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileData := []byte("\x00\x01binary\xff")
+	fw.Write(fileData)
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var title, filename string
+	var got []byte
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, hdr, err := r.FormFile("file")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer f.Close()
+		if got, err = io.ReadAll(f); err != nil {
+			t.Error(err)
+		}
+		title, filename = r.FormValue("title"), hdr.Filename
+	})
+	evt := testEvent("POST", "/upload")
+	evt.Headers["content-type"] = mw.FormDataContentType()
+	evt.Body, evt.IsBase64Encoded = base64.StdEncoding.EncodeToString(body.Bytes()), true
+	invoke(t, h, evt)
+	if !bytes.Equal(got, fileData) {
+		t.Errorf("got file content %q, want %q", got, fileData)
+	}
+	if filename != "data.bin" || title != "report" {
+		t.Errorf("got file name %q and title %q", filename, title)
+	}
+}
+
+func TestResponseBase64Encoding(t *testing.T) {
+	body := "\xfb\xff\xfe"
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.WriteString(w, body)
+	})
+	for _, tc := range []struct {
+		enc  *base64.Encoding
+		want string
+	}{
+		{nil, "+//+"},
+		{base64.StdEncoding, "+//+"},
+		{base64.URLEncoding, "-__-"},
+	} {
+		var opts []Option
+		if tc.enc != nil {
+			opts = append(opts, WithResponseBase64Encoding(tc.enc))
+		}
+		out := invoke(t, h, testEvent("GET", "/"), opts...)
+		if !out.IsBase64Encoded || out.Body != tc.want {
+			t.Errorf("got body %q, base64 %v, want %q", out.Body, out.IsBase64Encoded, tc.want)
+		}
+	}
+}
+
+func TestTrailers(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum, Cache-Control")
+		io.WriteString(w, "data")
+		w.Header().Set("X-Checksum", "abc")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set(http.TrailerPrefix+"X-Late", "1")
+	})
+	out := invoke(t, h, testEvent("GET", "/"))
+	if out.Body != "data" {
+		t.Errorf("got body %q, want %q", out.Body, "data")
+	}
+	for k, want := range map[string]string{"X-Checksum": "abc", "X-Late": "1", "Trailer": "", "Cache-Control": ""} {
+		if got := out.Headers[k]; got != want {
+			t.Errorf("got %s header %q, want %q", k, got, want)
+		}
+	}
+
+	// streaming format cannot carry trailers, so they are dropped
+	sout, err := StreamingHandler(h)(context.Background(), testEvent("GET", "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(sout.Body); string(b) != "data" {
+		t.Errorf("streaming: got body %q, want %q", b, "data")
+	}
+	for _, k := range []string{"Trailer", "X-Checksum", "X-Late"} {
+		if v, ok := sout.Headers[k]; ok {
+			t.Errorf("streaming: got %s header %q, want none", k, v)
+		}
+	}
+}
+
+func TestInvalidUTF8RequestBody(t *testing.T) {
+	const body = "a\xff\xfeb"
+	var got []byte
+	var contentLength int64
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		got, _ = io.ReadAll(r.Body)
+	})
+	evt := testEvent("POST", "/")
+	evt.Body = body
+	invoke(t, h, evt)
+	if string(got) != body || contentLength != int64(len(body)) {
+		t.Errorf("got body %q with ContentLength %d, want %q with %d", got, contentLength, body, len(body))
+	}
+
+	got = nil
+	if _, err := HandlerV1(h)(context.Background(), &events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/",
+		Body:       body,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != body {
+		t.Errorf("HandlerV1: got body %q, want %q", got, body)
+	}
+}
+
+func TestErrorPages(t *testing.T) {
+	pages := map[int]func(*http.Request) (string, []byte){
+		http.StatusNotFound: func(r *http.Request) (string, []byte) {
+			return "application/json", []byte(`{"error":"no ` + r.URL.Path + `"}`)
+		},
+	}
+	for _, tc := range []struct {
+		name, method     string
+		code             int
+		body             string
+		wantBody, wantCT string
+	}{
+		{"substituted", "GET", http.StatusNotFound, "", `{"error":"no /x"}`, "application/json"},
+		{"handler body", "GET", http.StatusNotFound, "custom", "custom", "text/plain; charset=utf-8"},
+		{"other status", "GET", http.StatusInternalServerError, "", "", ""},
+		{"head", "HEAD", http.StatusNotFound, "", "", ""},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.code)
+			io.WriteString(w, tc.body)
+		})
+		out := invoke(t, h, testEvent(tc.method, "/x"), WithErrorPages(pages))
+		if out.StatusCode != tc.code || out.Body != tc.wantBody || out.Headers["Content-Type"] != tc.wantCT {
+			t.Errorf("%s: got %d %q with Content-Type %q, want %d %q with %q", tc.name,
+				out.StatusCode, out.Body, out.Headers["Content-Type"], tc.code, tc.wantBody, tc.wantCT)
+		}
+	}
+}
+
+func TestMaxHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		extra        map[string]string
+		count, bytes int
+		wantCode     int
+	}{
+		{"within limits", map[string]string{"x-a": "1", "x-b": "2"}, 10, 1000, http.StatusOK},
+		{"count exceeded", map[string]string{"x-a": "1", "x-b": "2", "x-c": "3"}, 3, 0, http.StatusRequestHeaderFieldsTooLarge},
+		{"bytes exceeded", map[string]string{"x-a": strings.Repeat("v", 600)}, 0, 500, http.StatusRequestHeaderFieldsTooLarge},
+		{"no limits", map[string]string{"x-a": strings.Repeat("v", 600)}, 0, 0, http.StatusOK},
+	} {
+		var called bool
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+		evt := testEvent("GET", "/")
+		for k, v := range tc.extra {
+			evt.Headers[k] = v
+		}
+		out := invoke(t, h, evt, WithMaxHeaders(tc.count, tc.bytes))
+		if out.StatusCode != tc.wantCode {
+			t.Errorf("%s: got status %d, want %d", tc.name, out.StatusCode, tc.wantCode)
+		}
+		if called != (tc.wantCode == http.StatusOK) {
+			t.Errorf("%s: handler called: %v", tc.name, called)
+		}
+	}
+}
+
+func TestNoHijacker(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Hijacker); ok {
+			t.Error("ResponseWriter implements http.Hijacker")
+		}
+		if _, _, err := http.NewResponseController(w).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+			t.Errorf("ResponseController.Hijack: got %v, want %v", err, http.ErrNotSupported)
+		}
+	})
+	invoke(t, h, testEvent("GET", "/"))
+	if _, err := StreamingHandler(h)(context.Background(), testEvent("GET", "/")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultContentType(t *testing.T) {
+	for _, tc := range []struct {
+		name, set, body string
+		code            int
+		want            string
+	}{
+		{"unset", "", `{"a":1}`, http.StatusOK, "application/json"},
+		{"explicit", "text/csv", "a,b", http.StatusOK, "text/csv"},
+		{"no content", "", "", http.StatusNoContent, ""},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tc.set != "" {
+				w.Header().Set("Content-Type", tc.set)
+			}
+			w.WriteHeader(tc.code)
+			io.WriteString(w, tc.body)
+		})
+		out := invoke(t, h, testEvent("GET", "/"), WithDefaultContentType("application/json"))
+		if got := out.Headers["Content-Type"]; got != tc.want {
+			t.Errorf("%s: got Content-Type %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	// without the option content type is detected
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, `{"a":1}`) })
+	if got := invoke(t, h, testEvent("GET", "/")).Headers["Content-Type"]; got != "text/plain; charset=utf-8" {
+		t.Errorf("default: got Content-Type %q", got)
+	}
+}
+
+// BenchmarkLargeBody measures conversion of 1 MiB text and binary response
+// bodies: text is checked to be valid UTF-8, binary content type decides on
+// base64 encoding without such check.
+func BenchmarkLargeBody(b *testing.B) {
+	text := bytes.Repeat([]byte("lorem ipsum "), (1<<20)/12)
+	binary := make([]byte, 1<<20)
+	for i := range binary {
+		binary[i] = byte(i * 7)
+	}
+	for _, bc := range []struct {
+		name, contentType string
+		body              []byte
+		wantBase64        bool
+	}{
+		{"text", "text/plain", text, false},
+		{"binary", "application/octet-stream", binary, true},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", bc.contentType)
+			w.Write(bc.body)
+		})
+		fn := Handler(h)
+		evt := testEvent("GET", "/")
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(bc.body)))
+			for i := 0; i < b.N; i++ {
+				out, err := fn(context.Background(), evt)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if out.IsBase64Encoded != bc.wantBase64 {
+					b.Fatalf("got base64 %v, want %v", out.IsBase64Encoded, bc.wantBase64)
+				}
+			}
+		})
+	}
+}
+
+func TestRequestPath(t *testing.T) {
+	for _, tc := range []struct {
+		method, path, basePath, want string
+	}{
+		{"GET", "", "", "/"},
+		{"GET", "/", "", "/"},
+		{"GET", "a/b", "", "/a/b"},
+		{"GET", "/api/items", "/api", "/items"},
+		{"GET", "/api", "/api", "/"},
+		{"GET", "/apiary", "/api", "/apiary"},
+		{"GET", "/api/items", "api/", "/items"},
+		{"GET", "/api/v1/items", "/api/v1/", "/items"},
+		{"GET", "/other/items", "/api", "/other/items"},
+		{"OPTIONS", "*", "", "*"},
+		{"GET", "*", "", "/*"},
+	} {
+		h := newHandler(http.NotFoundHandler(), []Option{WithBasePath(tc.basePath)})
+		if got := h.requestPath(tc.method, tc.path); got != tc.want {
+			t.Errorf("%s %q with base path %q: got %q, want %q", tc.method, tc.path, tc.basePath, got, tc.want)
+		}
+	}
+}
+
+func TestBasePathNotFound(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "handler "+r.URL.Path) })
+	nf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "custom not found", http.StatusNotFound)
+	})
+	for _, tc := range []struct {
+		name, method, path string
+		opts               []Option
+		code               int
+		body               string
+	}{
+		{"under prefix", "GET", "/prod/items", nil, http.StatusOK, "handler /items"},
+		{"prefix itself", "GET", "/prod", nil, http.StatusOK, "handler /"},
+		{"outside prefix", "GET", "/other/items", nil, http.StatusNotFound, "404 page not found\n"},
+		{"partial segment", "GET", "/production", nil, http.StatusNotFound, "404 page not found\n"},
+		{"not found handler", "GET", "/other", []Option{WithNotFoundHandler(nf)}, http.StatusNotFound, "custom not found\n"},
+		{"server options", "OPTIONS", "*", nil, http.StatusOK, "handler *"},
+	} {
+		out := invoke(t, h, testEvent(tc.method, tc.path), append(tc.opts, WithBasePath("/prod"))...)
+		if out.StatusCode != tc.code || out.Body != tc.body {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, out.StatusCode, out.Body, tc.code, tc.body)
+		}
+	}
+}
+
+func TestOptionsAsterisk(t *testing.T) {
+	m := NewMux()
+	m.Handle("GET /items", routeName("list"))
+	m.Handle("PUT /items/{id}", routeName("put"))
+	for _, tc := range []struct {
+		method, path string
+		wantCode     int
+		wantAllow    string
+	}{
+		{"OPTIONS", "*", http.StatusNoContent, "GET, HEAD, OPTIONS, PUT"},
+		{"OPTIONS", "/items", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{"GET", "*", http.StatusNotFound, ""},
+	} {
+		out := invoke(t, m, testEvent(tc.method, tc.path))
+		if out.StatusCode != tc.wantCode {
+			t.Errorf("%s %s: got status %d, want %d", tc.method, tc.path, out.StatusCode, tc.wantCode)
+		}
+		if got := out.Headers["Allow"]; got != tc.wantAllow {
+			t.Errorf("%s %s: got Allow %q, want %q", tc.method, tc.path, got, tc.wantAllow)
+		}
+	}
+}
+
+func TestRequestHeaderCase(t *testing.T) {
+	var header http.Header
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { header = r.Header })
+	evt := testEvent("POST", "/")
+	evt.Headers["content-type"] = "application/json"
+	evt.Headers["authorization"] = "Bearer token"
+	evt.Headers["Accept"] = "text/html"
+	evt.Headers["accept"] = "application/json"
+	invoke(t, h, evt)
+	if got := header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("got Content-Type %q", got)
+	}
+	if got := header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("got Authorization %q", got)
+	}
+	accept := append([]string(nil), header.Values("Accept")...)
+	sort.Strings(accept)
+	if !reflect.DeepEqual(accept, []string{"application/json", "text/html"}) {
+		t.Errorf("got Accept values %q, want both merged", accept)
+	}
+}
+
+func TestJSONResponseNotBase64(t *testing.T) {
+	const body = `{"greeting":"héllo 👋","symbol":"€"}`
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		io.WriteString(w, body)
+	})
+	out := invoke(t, h, testEvent("GET", "/"))
+	if out.IsBase64Encoded || out.Body != body {
+		t.Errorf("got body %q, base64 %v, want %q as is", out.Body, out.IsBase64Encoded, body)
+	}
+}
+
+func TestTrimHeaderValues(t *testing.T) {
+	for _, tc := range []struct {
+		opts       []Option
+		wantAuth   string
+		wantCustom string
+	}{
+		{nil, "  Bearer abc", "\tvalue \t"},
+		{[]Option{WithTrimHeaderValues()}, "Bearer abc", "value"},
+	} {
+		var auth, custom string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+			custom = r.Header.Get("X-Custom")
+		})
+		evt := testEvent("GET", "/")
+		evt.Headers["authorization"] = "  Bearer abc"
+		evt.Headers["x-custom"] = "\tvalue \t"
+		invoke(t, h, evt, tc.opts...)
+		if auth != tc.wantAuth || custom != tc.wantCustom {
+			t.Errorf("with %d options: got Authorization %q, X-Custom %q, want %q, %q",
+				len(tc.opts), auth, custom, tc.wantAuth, tc.wantCustom)
+		}
+	}
+}
+
+func TestConnectionHeaderRemoved(t *testing.T) {
+	var header http.Header
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { header = r.Header })
+	evt := testEvent("GET", "/")
+	evt.Headers["connection"] = "keep-alive, X-Custom"
+	evt.Headers["keep-alive"] = "timeout=5"
+	evt.Headers["x-custom"] = "hop"
+	evt.Headers["x-other"] = "end-to-end"
+	invoke(t, h, evt)
+	for _, k := range []string{"Connection", "Keep-Alive", "X-Custom"} {
+		if v := header.Values(k); len(v) != 0 {
+			t.Errorf("got %s header %q, want it removed", k, v)
+		}
+	}
+	if got := header.Get("X-Other"); got != "end-to-end" {
+		t.Errorf("got X-Other %q, want it preserved", got)
+	}
+}
+
+func TestDateHeader(t *testing.T) {
+	clock := func() time.Time { return time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600)) }
+	for _, tc := range []struct {
+		name string
+		set  string
+		opts []Option
+		want string
+	}{
+		{"disabled", "", []Option{WithClock(clock)}, ""},
+		{"fixed clock", "", []Option{WithDateHeader(), WithClock(clock)}, "Fri, 01 Mar 2024 11:30:00 GMT"},
+		{"handler set", "Mon, 01 Jan 2024 00:00:00 GMT", []Option{WithDateHeader(), WithClock(clock)}, "Mon, 01 Jan 2024 00:00:00 GMT"},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tc.set != "" {
+				w.Header().Set("Date", tc.set)
+			}
+		})
+		out := invoke(t, h, testEvent("GET", "/"), tc.opts...)
+		if got := out.Headers["Date"]; got != tc.want {
+			t.Errorf("%s: got Date %q, want %q", tc.name, got, tc.want)
+		}
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	out := invoke(t, h, testEvent("GET", "/"), WithDateHeader())
+	if _, err := http.ParseTime(out.Headers["Date"]); err != nil {
+		t.Errorf("with default clock: %v", err)
+	}
+}
+
+func TestBase64RequestBody(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		isBase64 bool
+		want     string
+		wantErr  bool
+	}{
+		{"text", "hello", false, "hello", false},
+		{"base64", "aGVsbG8=", true, "hello", false},
+		{"binary", "/wAB", true, "\xff\x00\x01", false},
+		{"malformed", "aGVs!!!", true, "", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []byte
+			var readErr error
+			var contentLength int64
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentLength = r.ContentLength
+				got, readErr = io.ReadAll(r.Body)
+			})
+			evt := testEvent("POST", "/")
+			evt.Body, evt.IsBase64Encoded = tc.body, tc.isBase64
+			invoke(t, h, evt)
+			if tc.wantErr {
+				if readErr == nil {
+					t.Fatalf("reading malformed body: got %q, want error", got)
+				}
+				return
+			}
+			if readErr != nil {
+				t.Fatal(readErr)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got body %q, want %q", got, tc.want)
+			}
+			if contentLength != int64(len(tc.want)) {
+				t.Errorf("got ContentLength %d, want %d", contentLength, len(tc.want))
+			}
+		})
+	}
+}
+
+// BenchmarkLazyBody compares handler ignoring a 256 KiB base64-encoded
+// request body, which is then never decoded, with handler reading it.
+func BenchmarkLazyBody(b *testing.B) {
+	evt := testEvent("POST", "/")
+	evt.Body = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0xff, 0x00}, 128<<10))
+	evt.IsBase64Encoded = true
+	for _, bc := range []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"ignore", func(w http.ResponseWriter, r *http.Request) {}},
+		{"read", func(w http.ResponseWriter, r *http.Request) { io.Copy(io.Discard, r.Body) }},
+	} {
+		fn := Handler(bc.handler)
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := fn(context.Background(), evt); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRejectBodyOnGet(t *testing.T) {
+	for _, tc := range []struct {
+		method, body string
+		opts         []Option
+		wantCode     int
+	}{
+		{"GET", "payload", []Option{WithRejectBodyOnGet()}, http.StatusBadRequest},
+		{"HEAD", "payload", []Option{WithRejectBodyOnGet()}, http.StatusBadRequest},
+		{"GET", "", []Option{WithRejectBodyOnGet()}, http.StatusOK},
+		{"POST", "payload", []Option{WithRejectBodyOnGet()}, http.StatusOK},
+		{"GET", "payload", nil, http.StatusOK},
+	} {
+		var called bool
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+		evt := testEvent(tc.method, "/")
+		evt.Body = tc.body
+		out := invoke(t, h, evt, tc.opts...)
+		if out.StatusCode != tc.wantCode || called != (tc.wantCode == http.StatusOK) {
+			t.Errorf("%s with body %q, %d options: got %d, handler called: %v",
+				tc.method, tc.body, len(tc.opts), out.StatusCode, called)
+		}
+	}
+}
+
+func TestProto(t *testing.T) {
+	for _, tc := range []struct {
+		eventProto string
+		opts       []Option
+		want       string
+		wantMajor  int
+		wantMinor  int
+	}{
+		{"HTTP/1.1", nil, "HTTP/1.1", 1, 1},
+		{"HTTP/2.0", nil, "HTTP/2.0", 2, 0},
+		{"", nil, "HTTP/1.1", 1, 1},
+		{"garbage", nil, "HTTP/1.1", 1, 1},
+		{"HTTP/2.0", []Option{WithProto(1, 0)}, "HTTP/1.0", 1, 0},
+	} {
+		var proto string
+		var major, minor int
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proto, major, minor = r.Proto, r.ProtoMajor, r.ProtoMinor
+		})
+		evt := testEvent("GET", "/")
+		evt.RequestContext.HTTP.Protocol = tc.eventProto
+		invoke(t, h, evt, tc.opts...)
+		if proto != tc.want || major != tc.wantMajor || minor != tc.wantMinor {
+			t.Errorf("event protocol %q, %d options: got %s (%d.%d), want %s",
+				tc.eventProto, len(tc.opts), proto, major, minor, tc.want)
+		}
+	}
+}
+
+func TestInvalidUTF8TextResponseWarning(t *testing.T) {
+	for _, tc := range []struct {
+		contentType, body string
+		wantBase64        bool
+		wantLog           bool
+	}{
+		{"application/json", `{"a":"b"}`, false, false},
+		{"application/json", "{\"a\":\"\xff\"}", true, true},
+		{"text/plain", "caf\xe9", true, true},
+		{"application/octet-stream", "\xff", true, false},
+	} {
+		var buf bytes.Buffer
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tc.contentType)
+			io.WriteString(w, tc.body)
+		})
+		out := invoke(t, h, testEvent("GET", "/"), WithErrorLogger(log.New(&buf, "", 0)))
+		if out.IsBase64Encoded != tc.wantBase64 {
+			t.Errorf("%s %q: got base64 %v, want %v", tc.contentType, tc.body, out.IsBase64Encoded, tc.wantBase64)
+		}
+		if got := strings.Contains(buf.String(), "is not a valid UTF-8"); got != tc.wantLog {
+			t.Errorf("%s %q: got log %q", tc.contentType, tc.body, buf.String())
+		}
+	}
+}
+
+func TestParseQueryForm(t *testing.T) {
+	var value, body string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value = r.FormValue("q")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	})
+	evt := testEvent("POST", "/?q=from-query")
+	evt.Headers["content-type"] = "application/x-www-form-urlencoded"
+	evt.Body = "q=from-body&x=1"
+	invoke(t, h, evt, WithParseQueryForm())
+	if value != "from-query" || body != "q=from-body&x=1" {
+		t.Errorf("got form value %q, unread body %q", value, body)
+	}
+
+	// without the option FormValue consumes the body
+	invoke(t, h, evt)
+	if value != "from-body" || body != "" {
+		t.Errorf("default: got form value %q, unread body %q", value, body)
+	}
+}
+
+func TestMaxRequestSize(t *testing.T) {
+	pages := map[int]func(*http.Request) (string, []byte){
+		http.StatusRequestEntityTooLarge: func(*http.Request) (string, []byte) {
+			return "text/plain", []byte("too big")
+		},
+	}
+	for _, tc := range []struct {
+		name             string
+		body             string
+		opts             []Option
+		code             int
+		wantBody, wantCT string
+	}{
+		{"within limit", "0123", nil, http.StatusOK, "ok", "text/plain; charset=utf-8"},
+		{"over limit", "0123456789", nil, http.StatusRequestEntityTooLarge,
+			`{"error":"Request Entity Too Large","limit":4,"size":10}`, "application/json"},
+		{"error page", "0123456789", []Option{WithErrorPages(pages)},
+			http.StatusRequestEntityTooLarge, "too big", "text/plain"},
+	} {
+		var called bool
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			io.WriteString(w, "ok")
+		})
+		evt := testEvent("POST", "/")
+		evt.Body = tc.body
+		out := invoke(t, h, evt, append(tc.opts, WithMaxRequestSize(4))...)
+		if out.StatusCode != tc.code || out.Body != tc.wantBody || out.Headers["Content-Type"] != tc.wantCT {
+			t.Errorf("%s: got %d %q with Content-Type %q, want %d %q with %q", tc.name,
+				out.StatusCode, out.Body, out.Headers["Content-Type"], tc.code, tc.wantBody, tc.wantCT)
+		}
+		if called != (tc.code == http.StatusOK) {
+			t.Errorf("%s: handler called: %v", tc.name, called)
+		}
+	}
+}
+
+func TestBinaryNoContentType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	for _, tc := range []struct {
+		name string
+		set  []string
+		opts []Option
+		want string
+	}{
+		{"sniffed", nil, nil, "image/png"},
+		{"suppressed", []string{}, nil, "image/png"},
+		{"empty value", []string{""}, nil, "image/png"},
+		{"default", []string{}, []Option{WithDefaultContentType("application/x-custom")}, "application/x-custom"},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tc.set != nil {
+				w.Header()["Content-Type"] = tc.set
+			}
+			w.Write(png)
+		})
+		out := invoke(t, h, testEvent("GET", "/"), tc.opts...)
+		if got := out.Headers["Content-Type"]; got != tc.want {
+			t.Errorf("%s: got Content-Type %q, want %q", tc.name, got, tc.want)
+		}
+		if !out.IsBase64Encoded || out.Body != base64.StdEncoding.EncodeToString(png) {
+			t.Errorf("%s: got body %q, base64 %v", tc.name, out.Body, out.IsBase64Encoded)
+		}
+	}
+}
+
+func TestMaxResponseHeaderBytes(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		value, cookie string
+		code          int
+	}{
+		{"within limit", strings.Repeat("a", 10), "", http.StatusOK},
+		{"oversized header", strings.Repeat("a", 100), "", http.StatusInternalServerError},
+		{"oversized cookie", "", "id=" + strings.Repeat("b", 100), http.StatusInternalServerError},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tc.value != "" {
+				w.Header().Set("X-Big", tc.value)
+			}
+			if tc.cookie != "" {
+				w.Header().Add("Set-Cookie", tc.cookie)
+			}
+			io.WriteString(w, "ok")
+		})
+		var buf bytes.Buffer
+		out := invoke(t, h, testEvent("GET", "/x"), WithMaxResponseHeaderBytes(64),
+			WithErrorLogger(log.New(&buf, "", 0)))
+		if out.StatusCode != tc.code {
+			t.Errorf("%s: got status %d, want %d", tc.name, out.StatusCode, tc.code)
+		}
+		if tc.code == http.StatusInternalServerError {
+			if out.Headers["X-Big"] != "" || len(out.Cookies) != 0 || out.Body == "ok" {
+				t.Errorf("%s: handler response leaked: %+v", tc.name, out)
+			}
+			if !strings.Contains(buf.String(), "exceeds limit of 64 bytes") {
+				t.Errorf("%s: got log %q", tc.name, buf.String())
+			}
+		} else if buf.Len() != 0 {
+			t.Errorf("%s: unexpected log %q", tc.name, buf.String())
+		}
+	}
+}
+
+func TestUpgradeRejected(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Upgrade", "websocket")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	})
+	var buf bytes.Buffer
+	evt := testEvent("GET", "/ws")
+	evt.Headers["connection"] = "Upgrade"
+	evt.Headers["upgrade"] = "websocket"
+	out := invoke(t, h, evt, WithErrorLogger(log.New(&buf, "", 0)))
+	if out.StatusCode != http.StatusInternalServerError || out.Headers["Upgrade"] != "" {
+		t.Errorf("got status %d with Upgrade %q, want 500", out.StatusCode, out.Headers["Upgrade"])
+	}
+	if s := buf.String(); !strings.Contains(s, `protocol upgrade (Upgrade: "websocket")`) ||
+		!strings.Contains(s, "WebSocketHandler") {
+		t.Errorf("got log %q", s)
+	}
+}
+
+func TestPathUnescaping(t *testing.T) {
+	for _, tc := range []struct {
+		name, target      string
+		opts              []Option
+		code              int
+		wantPath, wantRaw string
+		wantLog           bool
+	}{
+		{"default", "/a%2Fb", nil, http.StatusOK, "/a%2Fb", "", false},
+		{"decoded", "/a%2Fb%20c", []Option{WithPathUnescaping(true)}, http.StatusOK, "/a/b c", "/a%2Fb%20c", false},
+		{"plain", "/a/b", []Option{WithPathUnescaping(true)}, http.StatusOK, "/a/b", "", false},
+		{"strict malformed", "/a%zzb", []Option{WithPathUnescaping(true)}, http.StatusBadRequest, "", "", false},
+		{"lenient malformed", "/a%zzb", []Option{WithPathUnescaping(false)}, http.StatusOK, "/a%zzb", "", true},
+	} {
+		var path, raw string
+		var called bool
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			path, raw = r.URL.Path, r.URL.RawPath
+		})
+		var buf bytes.Buffer
+		out := invoke(t, h, testEvent("GET", tc.target), append(tc.opts, WithErrorLogger(log.New(&buf, "", 0)))...)
+		if out.StatusCode != tc.code {
+			t.Errorf("%s: got status %d, want %d", tc.name, out.StatusCode, tc.code)
+		}
+		if called != (tc.code == http.StatusOK) {
+			t.Errorf("%s: handler called: %v", tc.name, called)
+		}
+		if called && (path != tc.wantPath || raw != tc.wantRaw) {
+			t.Errorf("%s: got path %q, raw path %q, want %q, %q", tc.name, path, raw, tc.wantPath, tc.wantRaw)
+		}
+		if got := strings.Contains(buf.String(), "malformed path escaping"); got != tc.wantLog {
+			t.Errorf("%s: got log %q", tc.name, buf.String())
+		}
+	}
+}
+
+func TestPreserveHeaderCase(t *testing.T) {
+	var canonical, raw, other string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		canonical = r.Header.Get("Sentry-Trace")
+		if vv := r.Header["sentry-trace"]; len(vv) != 0 {
+			raw = vv[0]
+		}
+		if vv := r.Header["x-Vendor-ID"]; len(vv) != 0 {
+			other = vv[0]
+		}
+		w.Header()["sentry-trace"] = []string{"reply"}
+	})
+	evt := testEvent("GET", "/")
+	evt.Headers["sentry-trace"] = "abc-1"
+	evt.Headers["x-Vendor-ID"] = "42"
+	out := invoke(t, h, evt, WithPreserveHeaderCase())
+	if canonical != "abc-1" || raw != "abc-1" || other != "42" {
+		t.Errorf("got canonical %q, raw %q, mixed-case %q", canonical, raw, other)
+	}
+	if out.Headers["sentry-trace"] != "reply" || out.Headers["Sentry-Trace"] != "" {
+		t.Errorf("got response headers %v", out.Headers)
+	}
+
+	// without the option only canonical keys are present
+	raw = ""
+	invoke(t, h, evt)
+	if canonical != "abc-1" || raw != "" {
+		t.Errorf("default: got canonical %q, raw %q", canonical, raw)
+	}
+}
+
+func TestAllowedMethods(t *testing.T) {
+	for _, tc := range []struct {
+		method    string
+		code      int
+		wantAllow string
+	}{
+		{"GET", http.StatusOK, ""},
+		{"HEAD", http.StatusOK, ""},
+		{"POST", http.StatusMethodNotAllowed, "GET, HEAD"},
+		{"DELETE", http.StatusMethodNotAllowed, "GET, HEAD"},
+	} {
+		var called bool
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+		out := invoke(t, h, testEvent(tc.method, "/"), WithAllowedMethods(http.MethodGet, http.MethodHead))
+		if out.StatusCode != tc.code || out.Headers["Allow"] != tc.wantAllow {
+			t.Errorf("%s: got %d with Allow %q, want %d with %q",
+				tc.method, out.StatusCode, out.Headers["Allow"], tc.code, tc.wantAllow)
+		}
+		if called != (tc.code == http.StatusOK) {
+			t.Errorf("%s: handler called: %v", tc.method, called)
+		}
+	}
+}
+
+func TestOptionsApplyInOrder(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "data") })
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"none", nil, "text/plain; charset=utf-8"},
+		{"single", []Option{WithDefaultContentType("text/csv")}, "text/csv"},
+		{"last wins", []Option{WithDefaultContentType("text/csv"), WithDefaultContentType("text/tab-separated-values")},
+			"text/tab-separated-values"},
+	} {
+		out := invoke(t, h, testEvent("GET", "/"), tc.opts...)
+		if got := out.Headers["Content-Type"]; got != tc.want {
+			t.Errorf("%s: got Content-Type %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestConnFields(t *testing.T) {
+	var r *http.Request
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) { r = req })
+	invoke(t, h, testEvent("GET", "/a%2Fb?x=1"))
+	if r.RemoteAddr != "192.0.2.1" || r.RequestURI != "/a%2Fb?x=1" {
+		t.Errorf("got RemoteAddr %q, RequestURI %q", r.RemoteAddr, r.RequestURI)
+	}
+	if r.TLS == nil || !r.TLS.HandshakeComplete || r.TLS.ServerName != "example.lambda-url.us-east-1.on.aws" {
+		t.Errorf("got TLS %+v", r.TLS)
+	}
+	invoke(t, h, testEvent("GET", "/prod/items"), WithBasePath("/prod"))
+	if r.URL.Path != "/items" || r.RequestURI != "/prod/items" {
+		t.Errorf("with base path: got path %q, RequestURI %q", r.URL.Path, r.RequestURI)
+	}
+
+	ctx := context.Background()
+	HandlerV1(h)(ctx, &events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/v1"})
+	if r.TLS == nil || r.RequestURI != "/v1" {
+		t.Errorf("HandlerV1: got TLS %+v, RequestURI %q", r.TLS, r.RequestURI)
+	}
+	for proto, secure := range map[string]bool{"https": true, "http": false} {
+		ALBHandler(h)(ctx, &events.ALBTargetGroupRequest{HTTPMethod: "GET", Path: "/alb",
+			Headers: map[string]string{"host": "example.com", "x-forwarded-proto": proto}})
+		if (r.TLS != nil) != secure || r.RequestURI != "/alb" {
+			t.Errorf("ALBHandler over %s: got TLS %+v, RequestURI %q", proto, r.TLS, r.RequestURI)
+		}
+	}
+}
+
+// BenchmarkPooledBody measures allocations of serving responses of various
+// sizes, which bodies are written to the pooled buffers.
+func BenchmarkPooledBody(b *testing.B) {
+	for _, size := range []int{1 << 10, 64 << 10, 1 << 20} {
+		body := bytes.Repeat([]byte("a"), size)
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write(body)
+		})
+		fn := Handler(h)
+		evt := testEvent("GET", "/")
+		b.Run(strconv.Itoa(size>>10)+"KiB", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				if _, err := fn(context.Background(), evt); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
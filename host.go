@@ -0,0 +1,53 @@
+package apig
+
+import (
+	"net/http"
+	"strings"
+)
+
+// HostSource specifies where the value of http.Request.Host comes from.
+type HostSource int
+
+const (
+	// HostFromHeader takes host from the Host header, falling back to the
+	// domain name from the event request context if header is missing.
+	// This is the default.
+	HostFromHeader HostSource = iota
+	// HostFromDomainName takes host from the domain name of the event
+	// request context, i.e. the domain name API Gateway or Function URL
+	// was called with.
+	HostFromDomainName
+	// HostFromForwarded takes host from the first value of the
+	// X-Forwarded-Host header, as set by proxies such as CloudFront,
+	// falling back to HostFromHeader behavior if header is missing.
+	HostFromForwarded
+	// HostNone leaves http.Request.Host empty, for handlers that derive
+	// host from some other source themselves. Host header is still
+	// available in http.Request.Header.
+	HostNone
+)
+
+// WithHostSource configures which source http.Request.Host is taken from.
+func WithHostSource(src HostSource) Option {
+	return func(c *config) { c.hostSource = src }
+}
+
+// host returns value for http.Request.Host according to the configured host
+// source.
+func (c *config) host(headers http.Header, domainName string) string {
+	switch c.hostSource {
+	case HostFromDomainName:
+		return domainName
+	case HostNone:
+		return ""
+	case HostFromForwarded:
+		if v := headers.Get("X-Forwarded-Host"); v != "" {
+			v, _, _ = strings.Cut(v, ",")
+			return strings.TrimSpace(v)
+		}
+	}
+	if v := headers.Get("Host"); v != "" {
+		return v
+	}
+	return domainName
+}
@@ -0,0 +1,49 @@
+package apig
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHostSource(t *testing.T) {
+	const domain = "abc.execute-api.us-east-1.amazonaws.com"
+	for _, tc := range []struct {
+		name    string
+		headers map[string]string
+		opts    []Option
+		want    string
+	}{
+		{"default", map[string]string{"host": "api.example.com"}, nil, "api.example.com"},
+		{"default without header", nil, nil, domain},
+		{"header", map[string]string{"host": "api.example.com"}, []Option{WithHostSource(HostFromHeader)}, "api.example.com"},
+		{"domain name", map[string]string{"host": "api.example.com"}, []Option{WithHostSource(HostFromDomainName)}, domain},
+		{"forwarded", map[string]string{"host": "api.example.com", "x-forwarded-host": "www.example.com, cdn.example.com"},
+			[]Option{WithHostSource(HostFromForwarded)}, "www.example.com"},
+		{"forwarded without header", map[string]string{"host": "api.example.com"},
+			[]Option{WithHostSource(HostFromForwarded)}, "api.example.com"},
+	} {
+		var got string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.Host })
+		evt := testEvent("GET", "/")
+		evt.Headers = tc.headers
+		evt.RequestContext.DomainName = domain
+		invoke(t, h, evt, tc.opts...)
+		if got != tc.want {
+			t.Errorf("%s: got host %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestHostNone(t *testing.T) {
+	var host, header string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { host, header = r.Host, r.Header.Get("Host") })
+	evt := testEvent("GET", "/")
+	evt.Headers["host"] = "d111111abcdef8.cloudfront.net"
+	invoke(t, h, evt, WithHostSource(HostNone))
+	if host != "" {
+		t.Errorf("got r.Host %q, want empty", host)
+	}
+	if header != "d111111abcdef8.cloudfront.net" {
+		t.Errorf("got Host header %q, want it preserved", header)
+	}
+}
@@ -0,0 +1,110 @@
+package apig
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// IdempotencyKeyHeader is the request header carrying the client-supplied
+// idempotency key, see IdempotencyKey.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyKey returns the idempotency key client sent with the request in
+// the Idempotency-Key header, and whether the key is present and not empty.
+func IdempotencyKey(r *http.Request) (string, bool) {
+	key := strings.TrimSpace(r.Header.Get(IdempotencyKeyHeader))
+	return key, key != ""
+}
+
+// StoredResponse is a response saved in IdempotencyStore.
+type StoredResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Base64, if not nil, tells whether Body is to be base64-encoded in the
+	// Lambda response, as Base64Header does.
+	Base64 *bool
+}
+
+// IdempotencyStore keeps responses to requests made with idempotency keys,
+// see WithIdempotency. Its methods may be called concurrently.
+type IdempotencyStore interface {
+	// Get returns response saved for the key, or nil if there is none, or
+	// if it has expired.
+	Get(ctx context.Context, key string) (*StoredResponse, error)
+	// Set saves response for the key; it should expire after ttl.
+	Set(ctx context.Context, key string, res *StoredResponse, ttl time.Duration) error
+}
+
+// WithIdempotency configures handler to honor idempotency keys of requests
+// with methods other than GET, HEAD, OPTIONS and TRACE: once such a request
+// with a given key (see IdempotencyKey) is served, repeated requests with the
+// same key get the saved response for ttl, without invoking the wrapped
+// http.Handler. Responses with 5xx status codes are not saved, so that
+// clients can retry. Store errors are logged, and the request is served as if
+// it had no key. Responses are saved before the steps that depend on the
+// request, such as compression or conditional requests handling, and the
+// replayed response goes through these steps for the repeated request.
+//
+// Keys are used as is, so clients of different users may collide on them:
+// store implementation may scope them by other request attributes it can get
+// from the context. Requests with the same key that arrive while the first
+// one is still being served all reach the handler.
+//
+// Idempotency keys only apply to buffered responses; with StreamingHandler
+// they are ignored.
+func WithIdempotency(store IdempotencyStore, ttl time.Duration) Option {
+	return func(c *config) { c.idempotencyStore, c.idempotencyTTL = store, ttl }
+}
+
+// idempotencyKey returns idempotency key of request r, if it should be
+// honored.
+func idempotencyKey(r *http.Request) (string, bool) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return "", false
+	}
+	return IdempotencyKey(r)
+}
+
+// storedResponse returns response saved for the key, or nil.
+func (h *lambdaHandler) storedResponse(r *http.Request, key string) *response {
+	res, err := h.idempotencyStore.Get(r.Context(), key)
+	if err != nil {
+		h.logf("apig: %s %s: getting response for idempotency key %q: %v", r.Method, r.URL.Path, key, err)
+		return nil
+	}
+	if res == nil {
+		return nil
+	}
+	header := res.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &response{status: res.StatusCode, header: header, body: res.Body, base64: res.Base64}
+}
+
+// storedCopy returns copy of res to be saved for idempotency key.
+func storedCopy(res *response) *StoredResponse {
+	return &StoredResponse{
+		StatusCode: res.status,
+		Header:     res.header.Clone(),
+		Body:       bytes.Clone(res.body), // res.body is pooled
+		Base64:     res.base64,
+	}
+}
+
+// storeResponse saves response saved for the key, unless the final response
+// res sent to the client is not to be saved.
+func (h *lambdaHandler) storeResponse(r *http.Request, key string, saved *StoredResponse, res *response) {
+	if res == nil || res.err != nil || res.status >= 500 || h.statusError(r, res) != nil {
+		return
+	}
+	err := h.idempotencyStore.Set(r.Context(), key, saved, h.idempotencyTTL)
+	if err != nil {
+		h.logf("apig: %s %s: saving response for idempotency key %q: %v", r.Method, r.URL.Path, key, err)
+	}
+}
@@ -0,0 +1,151 @@
+package apig
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mapStore struct {
+	mu  sync.Mutex
+	m   map[string]*StoredResponse
+	ttl time.Duration
+}
+
+func (s *mapStore) Get(_ context.Context, key string) (*StoredResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m[key], nil
+}
+
+func (s *mapStore) Set(_ context.Context, key string, res *StoredResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[string]*StoredResponse)
+	}
+	s.m[key], s.ttl = res, ttl
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	var calls int
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Call", strconv.Itoa(calls))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+		io.WriteString(w, "call "+strconv.Itoa(calls))
+	})
+	store := new(mapStore)
+	for _, tc := range []struct {
+		name, method, target, key string
+		wantCalls                 int
+		wantBody                  string
+	}{
+		{"first", "POST", "/orders", "k1", 1, "call 1"},
+		{"replayed", "POST", "/orders", "k1", 1, "call 1"},
+		{"other key", "POST", "/orders", "k2", 2, "call 2"},
+		{"no key", "POST", "/orders", "", 3, "call 3"},
+		{"safe method", "GET", "/orders", "k1", 4, "call 4"},
+		{"server error", "POST", "/fail", "k3", 5, "call 5"},
+		{"server error retried", "POST", "/fail", "k3", 6, "call 6"},
+	} {
+		evt := testEvent(tc.method, tc.target)
+		if tc.key != "" {
+			evt.Headers["idempotency-key"] = tc.key
+		}
+		out := invoke(t, h, evt, WithIdempotency(store, time.Hour))
+		if calls != tc.wantCalls || out.Body != tc.wantBody {
+			t.Errorf("%s: got body %q after %d handler calls, want %q after %d",
+				tc.name, out.Body, calls, tc.wantBody, tc.wantCalls)
+		}
+		if tc.name == "replayed" && (out.StatusCode != http.StatusCreated || out.Headers["X-Call"] != "1") {
+			t.Errorf("%s: got status %d with X-Call %q", tc.name, out.StatusCode, out.Headers["X-Call"])
+		}
+	}
+	if len(store.m) != 2 || store.ttl != time.Hour {
+		t.Errorf("got %d stored responses with ttl %v", len(store.m), store.ttl)
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   string
+		ok     bool
+	}{
+		{"abc-123", "abc-123", true},
+		{" abc ", "abc", true},
+		{"", "", false},
+		{"  ", "", false},
+	} {
+		r := &http.Request{Header: http.Header{IdempotencyKeyHeader: {tc.header}}}
+		if got, ok := IdempotencyKey(r); got != tc.want || ok != tc.ok {
+			t.Errorf("%q: got %q, %v, want %q, %v", tc.header, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestIdempotencyReplayEncoding(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/raw" {
+			w.Header().Set(Base64Header, "true")
+		}
+		io.WriteString(w, strings.Repeat("hello ", 100))
+	})
+	store := new(mapStore)
+	for _, tc := range []struct {
+		name, target, acceptEncoding string
+		wantEncoding                 string
+		wantBase64                   bool
+	}{
+		{"first gzip", "/orders", "gzip", "gzip", true},
+		{"replayed plain", "/orders", "", "", false},
+		{"replayed gzip", "/orders", "gzip", "gzip", true},
+		{"first base64", "/raw", "", "", true},
+		{"replayed base64", "/raw", "", "", true},
+	} {
+		evt := testEvent("POST", tc.target)
+		evt.Headers["idempotency-key"] = tc.target
+		if tc.acceptEncoding != "" {
+			evt.Headers["accept-encoding"] = tc.acceptEncoding
+		}
+		out := invoke(t, h, evt, WithIdempotency(store, time.Hour), WithCompression(0))
+		if got := out.Headers["Content-Encoding"]; got != tc.wantEncoding || out.IsBase64Encoded != tc.wantBase64 {
+			t.Errorf("%s: got Content-Encoding %q, base64 %v, want %q, %v",
+				tc.name, got, out.IsBase64Encoded, tc.wantEncoding, tc.wantBase64)
+		}
+		if tc.wantEncoding == "" && !tc.wantBase64 && !strings.HasPrefix(out.Body, "hello ") {
+			t.Errorf("%s: got body %.20q", tc.name, out.Body)
+		}
+	}
+	if len(store.m) != 2 {
+		t.Errorf("got %d stored responses, want 2", len(store.m))
+	}
+}
+
+func TestIdempotencyPanic(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	store := new(mapStore)
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("got panic value %v, want %q", v, "boom")
+		}
+		if len(store.m) != 0 {
+			t.Errorf("got %d stored responses", len(store.m))
+		}
+	}()
+	evt := testEvent("POST", "/orders")
+	evt.Headers["idempotency-key"] = "k1"
+	Handler(h, WithIdempotency(store, time.Hour))(context.Background(), evt)
+}
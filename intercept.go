@@ -0,0 +1,118 @@
+package apig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// WithResponseInterceptor configures a function called with the response
+// after the handler has served the request, but before the response is
+// converted to the Lambda response format. Function may modify status code,
+// headers, and replace the body. If the option is used several times,
+// functions are called in the order they were given. Response has its
+// Request field set to the request being served.
+//
+// Interceptors only apply to buffered responses; with StreamingHandler they
+// are not called.
+func WithResponseInterceptor(fn func(*http.Response)) Option {
+	return func(c *config) { c.interceptors = append(c.interceptors, fn) }
+}
+
+// WithRequestInterceptor configures a function called with the API Gateway
+// HTTP API (or Lambda Function URL) event before it is converted to
+// http.Request, so that it can inspect or modify the event. If fn returns an
+// error that is (or wraps) *HTTPError, response is built from that error, and
+// the wrapped http.Handler is not called; this can be used to answer some
+// requests, like health checks, right away. Other errors make the Lambda
+// invocation fail. If the option is used several times, functions are called
+// in the order they were given, until one of them returns an error.
+func WithRequestInterceptor(fn func(context.Context, *events.APIGatewayV2HTTPRequest) error) Option {
+	return func(c *config) { c.requestInterceptors = append(c.requestInterceptors, fn) }
+}
+
+// WithResponseEventInterceptor configures a function called with the API
+// Gateway HTTP API response after the response has been converted, right
+// before it is returned to the Lambda runtime. Unlike functions configured
+// with WithResponseInterceptor, fn sees the response exactly as it is sent,
+// such as with base64-encoded body. If the option is used several times,
+// functions are called in the order they were given. It does not apply to
+// StreamingHandler, or to responses in V1 format (see WithResponseFormat).
+func WithResponseEventInterceptor(fn func(context.Context, *events.APIGatewayV2HTTPResponse)) Option {
+	return func(c *config) { c.responseEventInterceptors = append(c.responseEventInterceptors, fn) }
+}
+
+// interceptEvent calls configured request interceptors on event req. If
+// interceptor returns *HTTPError, it returns response to send instead of
+// serving the request.
+func (h *lambdaHandler) interceptEvent(ctx context.Context, req *events.APIGatewayV2HTTPRequest) (*response, error) {
+	for _, fn := range h.requestInterceptors {
+		err := fn(ctx, req)
+		if err == nil {
+			continue
+		}
+		var herr *HTTPError
+		if !errors.As(err, &herr) {
+			return nil, err
+		}
+		buf := new(bytes.Buffer)
+		w := newResponseWriter(buf, "")
+		herr.write(w, &h.config)
+		status, header, _ := w.result()
+		return &response{status: status, header: header, body: buf.Bytes()}, nil
+	}
+	return nil, nil
+}
+
+// interceptResponseEvent calls configured response event interceptors on
+// out.
+func (h *lambdaHandler) interceptResponseEvent(ctx context.Context, out *events.APIGatewayV2HTTPResponse) {
+	for _, fn := range h.responseEventInterceptors {
+		fn(ctx, out)
+	}
+}
+
+// intercept calls configured response interceptors on res.
+func (h *lambdaHandler) intercept(r *http.Request, res *response) {
+	resp := &http.Response{
+		Status:        strconv.Itoa(res.status) + " " + http.StatusText(res.status),
+		StatusCode:    res.status,
+		Proto:         r.Proto,
+		ProtoMajor:    r.ProtoMajor,
+		ProtoMinor:    r.ProtoMinor,
+		Header:        res.header,
+		Body:          io.NopCloser(bytes.NewReader(res.body)),
+		ContentLength: int64(len(res.body)),
+		Request:       r,
+	}
+	body := resp.Body
+	for _, fn := range h.interceptors {
+		fn(resp)
+	}
+	res.status = resp.StatusCode
+	res.header = resp.Header
+	if res.header == nil {
+		res.header = make(http.Header)
+	}
+	if resp.Body == body {
+		return
+	}
+	res.header.Del("Content-Length")
+	if resp.Body == nil {
+		res.body = nil
+		return
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		h.logf("apig: %s %s: reading intercepted response body: %v", r.Method, r.URL.Path, err)
+		*res = *errorResponse(http.StatusInternalServerError)
+		return
+	}
+	res.body = b
+}
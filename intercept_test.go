@@ -0,0 +1,90 @@
+package apig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestResponseInterceptor(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, "hello")
+	})
+	var order []string
+	var path string
+	first := func(res *http.Response) {
+		order = append(order, "first")
+		path = res.Request.URL.Path
+		res.Header.Set("X-Content-Type-Options", "nosniff")
+		b, _ := io.ReadAll(res.Body)
+		res.Body = io.NopCloser(strings.NewReader(strings.ToUpper(string(b))))
+	}
+	second := func(res *http.Response) {
+		order = append(order, "second")
+		res.StatusCode = http.StatusAccepted
+	}
+	out := invoke(t, h, testEvent("GET", "/x"), WithResponseInterceptor(first), WithResponseInterceptor(second))
+	if out.StatusCode != http.StatusAccepted || out.Body != "HELLO" || out.Headers["X-Content-Type-Options"] != "nosniff" {
+		t.Errorf("got %d %q with headers %v", out.StatusCode, out.Body, out.Headers)
+	}
+	if strings.Join(order, ",") != "first,second" || path != "/x" {
+		t.Errorf("got calls %q with request path %q", order, path)
+	}
+}
+
+func TestEventInterceptors(t *testing.T) {
+	var served []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = append(served, r.URL.Path+" "+r.Header.Get("X-Injected"))
+		io.WriteString(w, "ok")
+	})
+	errFatal := errors.New("fatal")
+	inject := func(ctx context.Context, req *events.APIGatewayV2HTTPRequest) error {
+		req.Headers["x-injected"] = "1"
+		return nil
+	}
+	health := func(ctx context.Context, req *events.APIGatewayV2HTTPRequest) error {
+		switch req.RawPath {
+		case "/health":
+			return &HTTPError{Code: http.StatusOK, Message: "healthy"}
+		case "/fatal":
+			return fmt.Errorf("intercepting: %w", errFatal)
+		}
+		return nil
+	}
+	var seen []string
+	mark := func(ctx context.Context, out *events.APIGatewayV2HTTPResponse) {
+		seen = append(seen, out.Body)
+		out.Headers["X-Intercepted"] = "1"
+	}
+	fn := Handler(h, WithRequestInterceptor(inject), WithRequestInterceptor(health),
+		WithResponseEventInterceptor(mark))
+
+	out, err := fn(context.Background(), testEvent("GET", "/items"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Body != "ok" || out.Headers["X-Intercepted"] != "1" || len(served) != 1 || served[0] != "/items 1" {
+		t.Errorf("got %q with headers %v, served %q", out.Body, out.Headers, served)
+	}
+	out, err = fn(context.Background(), testEvent("GET", "/health"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.StatusCode != http.StatusOK || out.Body != "healthy\n" || len(served) != 1 {
+		t.Errorf("health: got %d %q, served %q", out.StatusCode, out.Body, served)
+	}
+	if _, err := fn(context.Background(), testEvent("GET", "/fatal")); !errors.Is(err, errFatal) {
+		t.Errorf("fatal: got error %v", err)
+	}
+	if strings.Join(seen, ",") != "ok,healthy\n" {
+		t.Errorf("response event interceptor saw %q", seen)
+	}
+}
@@ -0,0 +1,81 @@
+// Package httpevent converts http.Request to the API Gateway HTTP API event.
+package httpevent
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FromRequest converts r to an API Gateway HTTP API (payload format version
+// 2.0) event, the way Lambda Function URLs deliver it. It does the reverse
+// of the conversion done by apig.Handler: Cookie headers are put into the
+// Cookies field, other headers are lowercased and their values joined by
+// commas, and request body that is not a valid UTF-8 is base64-encoded.
+// It reads r.Body, replacing it with a reader over the same content.
+func FromRequest(r *http.Request) *events.APIGatewayV2HTTPRequest {
+	now := time.Now()
+	evt := &events.APIGatewayV2HTTPRequest{
+		Version:        "2.0",
+		RouteKey:       "$default",
+		RawPath:        r.URL.EscapedPath(),
+		RawQueryString: r.URL.RawQuery,
+		Headers:        make(map[string]string, len(r.Header)),
+	}
+	for k, vv := range r.Header {
+		if strings.EqualFold(k, "Cookie") {
+			for _, v := range vv {
+				for _, c := range strings.Split(v, ";") {
+					if c = strings.TrimSpace(c); c != "" {
+						evt.Cookies = append(evt.Cookies, c)
+					}
+				}
+			}
+			continue
+		}
+		evt.Headers[strings.ToLower(k)] = strings.Join(vv, ",")
+	}
+	if r.Host != "" {
+		evt.Headers["host"] = r.Host
+	}
+	if q := r.URL.Query(); len(q) != 0 {
+		evt.QueryStringParameters = make(map[string]string, len(q))
+		for k, vv := range q {
+			evt.QueryStringParameters[k] = strings.Join(vv, ",")
+		}
+	}
+	rc := &evt.RequestContext
+	rc.RouteKey = evt.RouteKey
+	rc.Stage = "$default"
+	rc.DomainName = r.Host
+	rc.DomainPrefix, _, _ = strings.Cut(r.Host, ".")
+	rc.Time = now.Format("02/Jan/2006:15:04:05 -0700")
+	rc.TimeEpoch = now.UnixMilli()
+	rc.HTTP.Method = r.Method
+	rc.HTTP.Path = evt.RawPath
+	rc.HTTP.Protocol = r.Proto
+	rc.HTTP.UserAgent = r.UserAgent()
+	rc.HTTP.SourceIP = r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		rc.HTTP.SourceIP = host
+	}
+	if r.Body != nil {
+		body, _ := io.ReadAll(r.Body)
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if utf8.Valid(body) {
+			evt.Body = string(body)
+		} else {
+			evt.Body = base64.StdEncoding.EncodeToString(body)
+			evt.IsBase64Encoded = true
+		}
+	}
+	return evt
+}
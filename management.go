@@ -0,0 +1,200 @@
+package apig
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ManagementAPI is a subset of API Gateway Management API used by
+// ManagementClient. Endpoint is the management API URL of the WebSocket API
+// stage, such as "https://{api-id}.execute-api.{region}.amazonaws.com/{stage}".
+//
+// By default ManagementClient uses a built-in implementation which calls the
+// API over HTTPS signing requests with credentials from the environment,
+// that Lambda runtime provides. Implement this interface over an AWS SDK
+// client and configure it with WithManagementAPI to use SDK instead.
+type ManagementAPI interface {
+	PostToConnection(ctx context.Context, endpoint, connectionID string, data []byte) error
+	DeleteConnection(ctx context.Context, endpoint, connectionID string) error
+}
+
+// WithManagementAPI configures ManagementAPI implementation used by
+// ManagementClient created for requests of WebSocketHandler.
+func WithManagementAPI(api ManagementAPI) Option {
+	return func(c *config) { c.managementAPI = api }
+}
+
+// ManagementClient sends messages to WebSocket API clients.
+type ManagementClient struct {
+	ctx      context.Context
+	api      ManagementAPI
+	endpoint string
+}
+
+// NewManagementClient returns ManagementClient for the WebSocket API stage
+// request r was received from. Request must be created by WebSocketHandler.
+// Context ctx is used for all calls made by the client.
+func NewManagementClient(ctx context.Context, r *http.Request) (*ManagementClient, error) {
+	evt, ok := r.Context().Value(wsEventKey).(*events.APIGatewayWebsocketProxyRequest)
+	if !ok {
+		return nil, errors.New("apig: request is not a WebSocket API request")
+	}
+	rc := &evt.RequestContext
+	host := rc.DomainName
+	if region := os.Getenv("AWS_REGION"); rc.APIID != "" && region != "" {
+		// domain name may be a custom one, management API is only
+		// available on the default one
+		host = rc.APIID + ".execute-api." + region + ".amazonaws.com"
+	}
+	if host == "" {
+		return nil, errors.New("apig: cannot derive management API endpoint from request")
+	}
+	api, ok := r.Context().Value(managementAPIKey).(ManagementAPI)
+	if !ok {
+		api = sigV4ManagementAPI{client: http.DefaultClient}
+	}
+	return &ManagementClient{
+		ctx:      ctx,
+		api:      api,
+		endpoint: "https://" + host + "/" + rc.Stage,
+	}, nil
+}
+
+// Endpoint returns management API endpoint used by the client.
+func (c *ManagementClient) Endpoint() string { return c.endpoint }
+
+// Send sends data to the client with the given connection ID.
+func (c *ManagementClient) Send(connectionID string, data []byte) error {
+	return c.api.PostToConnection(c.ctx, c.endpoint, connectionID, data)
+}
+
+// Disconnect closes connection with the given connection ID.
+func (c *ManagementClient) Disconnect(connectionID string) error {
+	return c.api.DeleteConnection(c.ctx, c.endpoint, connectionID)
+}
+
+// sigV4ManagementAPI implements ManagementAPI over plain HTTP, signing
+// requests with AWS Signature Version 4 using credentials from the
+// environment.
+type sigV4ManagementAPI struct {
+	client *http.Client
+}
+
+func (api sigV4ManagementAPI) PostToConnection(ctx context.Context, endpoint, connectionID string, data []byte) error {
+	return api.do(ctx, http.MethodPost, endpoint, connectionID, data)
+}
+
+func (api sigV4ManagementAPI) DeleteConnection(ctx context.Context, endpoint, connectionID string) error {
+	return api.do(ctx, http.MethodDelete, endpoint, connectionID, nil)
+}
+
+func (api sigV4ManagementAPI) do(ctx context.Context, method, endpoint, connectionID string, data []byte) error {
+	u := strings.TrimSuffix(endpoint, "/") + "/@connections/" + url.PathEscape(connectionID)
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if err := signV4(req, data, "execute-api", time.Now()); err != nil {
+		return err
+	}
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("apig: %s %s: %s: %s", method, u, resp.Status, bytes.TrimSpace(msg))
+}
+
+// signV4 signs request with AWS Signature Version 4 using credentials and
+// region from the environment.
+func signV4(req *http.Request, body []byte, service string, now time.Time) error {
+	keyID, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	region := os.Getenv("AWS_REGION")
+	if keyID == "" || secret == "" || region == "" {
+		return errors.New("apig: AWS credentials or region not found in the environment")
+	}
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		req.Header.Set("X-Amz-Security-Token", token)
+	}
+	payloadHash := sha256.Sum256(body)
+
+	signed := []string{"host"}
+	for k := range req.Header {
+		signed = append(signed, strings.ToLower(k))
+	}
+	sort.Strings(signed)
+	var canonHeaders strings.Builder
+	for _, k := range signed {
+		v := req.URL.Host
+		if k != "host" {
+			v = strings.Join(req.Header.Values(k), ",")
+		}
+		canonHeaders.WriteString(k + ":" + strings.TrimSpace(v) + "\n")
+	}
+	segments := strings.Split(req.URL.EscapedPath(), "/")
+	for i := range segments {
+		segments[i] = sigV4Escape(segments[i])
+	}
+	canonRequest := strings.Join([]string{
+		req.Method,
+		strings.Join(segments, "/"),
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		strings.Join(signed, ";"),
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	canonHash := sha256.Sum256([]byte(canonRequest))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonHash[:])
+	key := []byte("AWS4" + secret)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+keyID+"/"+scope+
+		", SignedHeaders="+strings.Join(signed, ";")+
+		", Signature="+hex.EncodeToString(hmacSHA256(key, toSign)))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sigV4Escape percent-encodes everything except unreserved characters, as
+// required by Signature Version 4.
+func sigV4Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
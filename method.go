@@ -0,0 +1,83 @@
+package apig
+
+import (
+	"net/http"
+	"strings"
+)
+
+var standardMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// normalizeMethod returns method in its canonical form: standard methods and
+// methods from the extra list are matched case-insensitively and returned in
+// their registered form, other methods are returned unchanged. It reports
+// false if method is not a valid HTTP token.
+func normalizeMethod(method string, extra []string) (string, bool) {
+	if !validToken(method) {
+		return "", false
+	}
+	for _, m := range standardMethods {
+		if strings.EqualFold(m, method) {
+			return m, true
+		}
+	}
+	for _, m := range extra {
+		if strings.EqualFold(m, method) {
+			return m, true
+		}
+	}
+	return method, true
+}
+
+// validToken reports whether s is a non-empty token as defined by RFC 7230,
+// section 3.2.6.
+func validToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// WithAllowedMethods configures handler to only pass requests with the given
+// methods to the wrapped http.Handler. Requests with other methods get 405
+// Method Not Allowed response with the Allow header listing methods, in the
+// order given:
+//
+//	apig.WithAllowedMethods(http.MethodGet, http.MethodHead)
+//
+// Methods are matched case-insensitively, the same way the standard ones are
+// normalized.
+func WithAllowedMethods(methods ...string) Option {
+	return func(c *config) { c.allowedMethods = methods }
+}
+
+// methodNotAllowed returns 405 response if method of r is not allowed by
+// WithAllowedMethods, or nil otherwise.
+func (c *config) methodNotAllowed(r *http.Request) *response {
+	for _, m := range c.allowedMethods {
+		if strings.EqualFold(m, r.Method) {
+			return nil
+		}
+	}
+	res := errorResponse(http.StatusMethodNotAllowed)
+	res.header.Set("Allow", strings.Join(c.allowedMethods, ", "))
+	return res
+}
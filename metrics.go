@@ -0,0 +1,151 @@
+package apig
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// WithEMFMetrics enables writing a CloudWatch Embedded Metric Format record
+// to stdout after each request is served, so that CloudWatch extracts
+// metrics from function logs. Metrics are put into the given namespace with
+// dimensions Method (request method) and StatusClass ("2xx", "4xx", etc.):
+//
+//   - Latency: time spent serving the request, in milliseconds;
+//   - ResponseBytes: size of the response body, before any base64 encoding;
+//   - ColdStart: 1 for the first request served by the process, otherwise 0.
+//
+// For StreamingHandler, record is written once the handler returns, and
+// ResponseBytes is the size of the body as written by the handler, before
+// compression.
+func WithEMFMetrics(namespace string) Option {
+	return func(c *config) {
+		c.emfNamespace = namespace
+		c.emfOut = os.Stdout
+	}
+}
+
+// WithSlowRequestLog enables logging of requests that took handler longer
+// than threshold to serve, using the logger configured with WithErrorLogger.
+// For StreamingHandler, the whole time until handler returns is taken into
+// account.
+func WithSlowRequestLog(threshold time.Duration) Option {
+	return func(c *config) { c.slowThreshold = threshold }
+}
+
+// WithAccessLog enables writing a structured access log record to l after
+// each request is served. Record has "request" message and Info level, and
+// the following attributes:
+//
+//   - method, path: request method and path;
+//   - status: response status code;
+//   - latency: time spent serving the request;
+//   - size: size of the response body, before any base64 encoding;
+//   - source_ip: client IP address, see ConnInfo;
+//   - request_id: ID of the request, see RequestID;
+//   - cold_start: true for the first request served by the process.
+//
+// For StreamingHandler, record is written once the handler returns, and size
+// is the size of the body as written by the handler, before compression.
+func WithAccessLog(l *slog.Logger) Option {
+	return func(c *config) { c.accessLog = l }
+}
+
+// timed reports whether time spent serving requests has to be measured.
+func (c *config) timed() bool {
+	return c.emfNamespace != "" || c.slowThreshold > 0 || c.accessLog != nil
+}
+
+// served is called after request r is served if timed reports true.
+func (h *lambdaHandler) served(r *http.Request, status, size int, latency time.Duration) {
+	if h.slowThreshold > 0 && latency > h.slowThreshold {
+		h.logf("apig: %s %s: slow request: served in %v", r.Method, r.URL.Path, latency)
+	}
+	if h.emfNamespace != "" {
+		h.emitMetrics(r, status, size, latency)
+	}
+	if h.accessLog != nil {
+		h.logAccess(r, status, size, latency)
+	}
+}
+
+// coldStartLogged is set once the first access log record is written.
+var coldStartLogged uint32
+
+// logAccess writes access log record for the served request.
+func (h *lambdaHandler) logAccess(r *http.Request, status, size int, latency time.Duration) {
+	ctx := r.Context()
+	h.accessLog.LogAttrs(ctx, slog.LevelInfo, "request",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Duration("latency", latency),
+		slog.Int("size", size),
+		slog.String("source_ip", ConnInfo(ctx).SourceIP),
+		slog.String("request_id", RequestID(ctx)),
+		slog.Bool("cold_start", atomic.CompareAndSwapUint32(&coldStartLogged, 0, 1)),
+	)
+}
+
+// coldStartReported is set once the first metrics record is written.
+var coldStartReported uint32
+
+// emfRecord is a log record in CloudWatch Embedded Metric Format.
+type emfRecord struct {
+	AWS struct {
+		Timestamp         int64          `json:"Timestamp"`
+		CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+	} `json:"_aws"`
+	Method        string  `json:"Method"`
+	StatusClass   string  `json:"StatusClass"`
+	Latency       float64 `json:"Latency"`
+	ResponseBytes int     `json:"ResponseBytes"`
+	ColdStart     int     `json:"ColdStart"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit"`
+}
+
+var emfMetrics = []emfMetric{
+	{Name: "Latency", Unit: "Milliseconds"},
+	{Name: "ResponseBytes", Unit: "Bytes"},
+	{Name: "ColdStart", Unit: "Count"},
+}
+
+// emitMetrics writes EMF record for the served request.
+func (h *lambdaHandler) emitMetrics(r *http.Request, status, size int, latency time.Duration) {
+	var rec emfRecord
+	rec.AWS.Timestamp = h.now().UnixMilli()
+	rec.AWS.CloudWatchMetrics = []emfDirective{{
+		Namespace:  h.emfNamespace,
+		Dimensions: [][]string{{"Method", "StatusClass"}},
+		Metrics:    emfMetrics,
+	}}
+	rec.Method = r.Method
+	rec.StatusClass = strconv.Itoa(status/100) + "xx"
+	rec.Latency = float64(latency) / float64(time.Millisecond)
+	rec.ResponseBytes = size
+	if atomic.CompareAndSwapUint32(&coldStartReported, 0, 1) {
+		rec.ColdStart = 1
+	}
+	b, err := json.Marshal(&rec)
+	if err != nil {
+		h.logf("apig: %s %s: encoding metrics: %v", r.Method, r.URL.Path, err)
+		return
+	}
+	if _, err := h.emfOut.Write(append(b, '\n')); err != nil {
+		h.logf("apig: %s %s: writing metrics: %v", r.Method, r.URL.Path, err)
+	}
+}
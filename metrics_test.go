@@ -0,0 +1,124 @@
+package apig
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEMFMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "12345")
+	})
+	invoke(t, h, testEvent("POST", "/"), WithEMFMetrics("App"), func(c *config) { c.emfOut = &buf })
+	var rec struct {
+		AWS struct {
+			Timestamp         int64
+			CloudWatchMetrics []struct {
+				Namespace  string
+				Dimensions [][]string
+				Metrics    []struct{ Name, Unit string }
+			}
+		} `json:"_aws"`
+		Method, StatusClass string
+		Latency             float64
+		ResponseBytes       int
+		ColdStart           int
+	}
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("%v: %q", err, buf.Bytes())
+	}
+	if rec.Method != "POST" || rec.StatusClass != "2xx" || rec.ResponseBytes != 5 || rec.Latency < 0 {
+		t.Errorf("got record %+v", rec)
+	}
+	if rec.ColdStart != 0 && rec.ColdStart != 1 {
+		t.Errorf("got ColdStart %d", rec.ColdStart)
+	}
+	if len(rec.AWS.CloudWatchMetrics) != 1 || rec.AWS.CloudWatchMetrics[0].Namespace != "App" ||
+		len(rec.AWS.CloudWatchMetrics[0].Metrics) != 3 || rec.AWS.Timestamp == 0 {
+		t.Errorf("got metadata %+v", rec.AWS)
+	}
+}
+
+func TestSlowRequestLog(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sleep   time.Duration
+		wantLog bool
+	}{
+		{"fast", 0, false},
+		{"slow", 20 * time.Millisecond, true},
+	} {
+		var buf bytes.Buffer
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { time.Sleep(tc.sleep) })
+		invoke(t, h, testEvent("GET", "/report"),
+			WithErrorLogger(log.New(&buf, "", 0)), WithSlowRequestLog(10*time.Millisecond))
+		if got := strings.Contains(buf.String(), "GET /report: slow request: served in "); got != tc.wantLog {
+			t.Errorf("%s: got log %q", tc.name, buf.String())
+		}
+	}
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "abc")
+	})
+	evt := testEvent("PUT", "/items/1")
+	evt.RequestContext.RequestID = "req-1"
+	invoke(t, h, evt, WithAccessLog(slog.New(slog.NewJSONHandler(&buf, nil))))
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("%v: %q", err, buf.Bytes())
+	}
+	for k, want := range map[string]any{
+		"msg":        "request",
+		"method":     "PUT",
+		"path":       "/items/1",
+		"status":     float64(http.StatusAccepted),
+		"size":       float64(3),
+		"source_ip":  "192.0.2.1",
+		"request_id": "req-1",
+	} {
+		if rec[k] != want {
+			t.Errorf("got %s %v, want %v", k, rec[k], want)
+		}
+	}
+	if _, ok := rec["latency"].(float64); !ok {
+		t.Errorf("got latency %v", rec["latency"])
+	}
+	if _, ok := rec["cold_start"].(bool); !ok {
+		t.Errorf("got cold_start %v", rec["cold_start"])
+	}
+}
+
+func TestServedPanic(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	for _, tc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"access log", []Option{WithAccessLog(slog.New(slog.NewJSONHandler(io.Discard, nil)))}},
+		{"emf metrics", []Option{WithEMFMetrics("App"), func(c *config) { c.emfOut = io.Discard }}},
+		{"slow request log", []Option{WithSlowRequestLog(time.Nanosecond), WithErrorLogger(log.New(io.Discard, "", 0))}},
+	} {
+		func() {
+			defer func() {
+				if v := recover(); v != "boom" {
+					t.Errorf("%s: got panic value %v, want %q", tc.name, v, "boom")
+				}
+			}()
+			Handler(h, tc.opts...)(context.Background(), testEvent("GET", "/"))
+		}()
+	}
+}
@@ -0,0 +1,97 @@
+package apig
+
+import (
+	"mime"
+	"strings"
+)
+
+// isTextContentType reports whether content type ct describes textual data.
+func isTextContentType(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mt, "text/"),
+		strings.HasSuffix(mt, "+json"),
+		strings.HasSuffix(mt, "+xml"):
+		return true
+	}
+	switch mt {
+	case "application/json",
+		"application/xml",
+		"application/javascript",
+		"application/ecmascript",
+		"application/x-www-form-urlencoded",
+		"image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// isBinaryContentType reports whether content type ct certainly describes
+// binary data, so that it has to be base64-encoded regardless of its content.
+// Types that may carry text, like application/json, must not be reported
+// here: their bodies are sent verbatim as long as they are valid UTF-8, which
+// keeps multi-byte characters intact and readable in the Lambda response.
+func isBinaryContentType(ct string) bool {
+	if ct == "" {
+		return false
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mt == "image/svg+xml":
+		return false
+	case strings.HasPrefix(mt, "application/grpc-web+"):
+		// gRPC-Web frames; note that application/grpc-web-text is
+		// base64 text itself
+		return true
+	case strings.HasPrefix(mt, "image/"),
+		strings.HasPrefix(mt, "audio/"),
+		strings.HasPrefix(mt, "video/"),
+		strings.HasPrefix(mt, "font/"):
+		return true
+	}
+	switch mt {
+	case "application/octet-stream",
+		"application/zip",
+		"application/gzip",
+		"application/pdf",
+		"application/wasm",
+		"application/grpc",
+		"application/grpc-web":
+		return true
+	}
+	return false
+}
+
+// matchContentType reports whether media type of content type ct matches any
+// of patterns. Pattern is either a media type ("application/json"), a type
+// with any subtype ("text/*"), or a suffix starting with "*"
+// ("*+json").
+func matchContentType(patterns []string, ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	for _, p := range patterns {
+		switch {
+		case p == "*/*":
+			return true
+		case strings.HasSuffix(p, "/*"):
+			if strings.HasPrefix(mt, p[:len(p)-1]) {
+				return true
+			}
+		case strings.HasPrefix(p, "*"):
+			if strings.HasSuffix(mt, p[1:]) {
+				return true
+			}
+		case strings.EqualFold(p, mt):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package apig
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Mux is an http.Handler that dispatches requests using route keys in the
+// format API Gateway HTTP API uses: "GET /users/{id}", "ANY /files/{path+}",
+// "$default".
+//
+// A path segment of the form "{name}" matches any single segment, a final
+// segment of the form "{name+}" matches the rest of the path. Method "ANY"
+// matches every method, and GET routes also match HEAD requests. The
+// "$default" route handles requests not matched by any other route.
+//
+// If several routes match the request, the most specific one is used, as API
+// Gateway does: the one with literal path segments wins over the one with
+// "{name}" in their place, which wins over "{name+}", so "GET /users/me" is
+// picked over "GET /users/{id}" regardless of registration order. Among
+// routes with equally specific paths, route with the request method wins over
+// GET route matching HEAD request, which wins over ANY route.
+//
+// Requests for paths that have routes registered, but not for the request
+// method, get 405 Method Not Allowed response with an Allow header listing
+// registered methods, unless there is a "$default" route.
+//
+// OPTIONS requests for paths that have no explicit OPTIONS (or ANY) route are
+// answered automatically with 204 No Content and an Allow header listing
+// methods registered for the path. "OPTIONS *" lists all registered methods.
+//
+// Requests not matched by any route get 404 Not Found response, or are
+// served by the handler configured with WithNotFoundHandler.
+type Mux struct {
+	routes []route
+	def    http.Handler // $default route
+}
+
+// NewMux returns a new empty Mux.
+func NewMux() *Mux { return &Mux{} }
+
+// Handle registers handler for the given route key. It panics if route key is
+// malformed or handler is nil.
+func (m *Mux) Handle(routeKey string, handler http.Handler) {
+	if handler == nil {
+		panic("apig: nil handler")
+	}
+	if routeKey == "$default" {
+		m.def = handler
+		return
+	}
+	rt, ok := parseRouteKey(routeKey)
+	if !ok {
+		panic("apig: invalid route key " + routeKey)
+	}
+	rt.handler = handler
+	m.routes = append(m.routes, rt)
+}
+
+// SetDefault registers handler for requests not matched by any other route,
+// the same as Handle("$default", handler). Note that the route key in the
+// event is not consulted: Lambda Function URLs report "$default" route key
+// for every request, so the request path is always matched against the
+// registered routes first.
+func (m *Mux) SetDefault(handler http.Handler) { m.Handle("$default", handler) }
+
+// HandleFunc registers handler function for the given route key.
+func (m *Mux) HandleFunc(routeKey string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(routeKey, http.HandlerFunc(handler))
+}
+
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions && r.URL.Path == "*" {
+		optionsReply(w, m.allowed(nil))
+		return
+	}
+	segments := splitPath(r.URL.Path)
+	var best *route
+	var bestRank int
+	for i := range m.routes {
+		rt := &m.routes[i]
+		rank, ok := rt.matchMethod(r.Method)
+		if !ok || !rt.matchPath(segments) {
+			continue
+		}
+		if best == nil || rt.moreSpecific(best) || !best.moreSpecific(rt) && rank < bestRank {
+			best, bestRank = rt, rank
+		}
+	}
+	if best != nil {
+		best.handler.ServeHTTP(w, r)
+		return
+	}
+	allowed := m.allowed(segments)
+	if r.Method == http.MethodOptions && len(allowed) != 0 {
+		optionsReply(w, allowed)
+		return
+	}
+	if m.def != nil {
+		m.def.ServeHTTP(w, r)
+		return
+	}
+	if len(allowed) != 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	notFound(w, r)
+}
+
+// notFound serves request r with the handler configured with
+// WithNotFoundHandler, or with a plain 404 Not Found response.
+func notFound(w http.ResponseWriter, r *http.Request) {
+	if c, _ := r.Context().Value(configKey).(*config); c != nil && c.notFound != nil {
+		c.notFound.ServeHTTP(w, r)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// allowed returns sorted list of methods registered for the path given as
+// its segments. If segments is nil, methods of all routes are returned.
+func (m *Mux) allowed(segments []string) []string {
+	seen := make(map[string]struct{})
+	for _, rt := range m.routes {
+		if rt.method == "ANY" {
+			continue
+		}
+		if segments != nil && !rt.matchPath(segments) {
+			continue
+		}
+		seen[rt.method] = struct{}{}
+		if rt.method == http.MethodGet {
+			seen[http.MethodHead] = struct{}{}
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(seen))
+	for k := range seen {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func optionsReply(w http.ResponseWriter, allowed []string) {
+	if i := sort.SearchStrings(allowed, http.MethodOptions); i == len(allowed) || allowed[i] != http.MethodOptions {
+		allowed = append(allowed, http.MethodOptions)
+		sort.Strings(allowed)
+	}
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type route struct {
+	method   string
+	segments []string
+	handler  http.Handler
+}
+
+func parseRouteKey(key string) (route, bool) {
+	method, path, ok := strings.Cut(key, " ")
+	if !ok || method == "" || !strings.HasPrefix(path, "/") {
+		return route{}, false
+	}
+	rt := route{method: method, segments: splitPath(path)}
+	for i, s := range rt.segments {
+		if strings.HasSuffix(s, "+}") && i != len(rt.segments)-1 {
+			return route{}, false
+		}
+	}
+	return rt, true
+}
+
+// matchMethod reports whether route matches request method, and the rank of
+// the match: exact method match ranks first, GET route matching HEAD request
+// next, and ANY route last.
+func (rt *route) matchMethod(method string) (int, bool) {
+	switch {
+	case rt.method == method:
+		return 0, true
+	case rt.method == http.MethodGet && method == http.MethodHead:
+		return 1, true
+	case rt.method == "ANY":
+		return 2, true
+	}
+	return 0, false
+}
+
+// moreSpecific reports whether route rt has more specific path than other,
+// both matching the same request path: comparing segments in order, literal
+// segment wins over "{name}", which wins over "{name+}".
+func (rt *route) moreSpecific(other *route) bool {
+	for i := 0; i < len(rt.segments) && i < len(other.segments); i++ {
+		a, b := segmentRank(rt.segments[i]), segmentRank(other.segments[i])
+		if a != b {
+			return a < b
+		}
+	}
+	return len(rt.segments) > len(other.segments)
+}
+
+func segmentRank(s string) int {
+	switch {
+	case isGreedy(s):
+		return 2
+	case isParam(s):
+		return 1
+	}
+	return 0
+}
+
+func (rt *route) matchPath(segments []string) bool {
+	for i, s := range rt.segments {
+		if isGreedy(s) {
+			return len(segments) > i
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if isParam(s) {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if s != segments[i] {
+			return false
+		}
+	}
+	return len(segments) == len(rt.segments)
+}
+
+func isParam(s string) bool  { return strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") }
+func isGreedy(s string) bool { return strings.HasPrefix(s, "{") && strings.HasSuffix(s, "+}") }
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return []string{}
+	}
+	return strings.Split(p, "/")
+}
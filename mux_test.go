@@ -0,0 +1,151 @@
+package apig
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// routeName returns handler writing name as the response body.
+func routeName(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, name) })
+}
+
+func TestMuxSpecificity(t *testing.T) {
+	m := NewMux()
+	// registered from the least specific to the most specific on purpose
+	m.Handle("ANY /{proxy+}", routeName("any proxy"))
+	m.Handle("GET /users/{rest+}", routeName("users rest"))
+	m.Handle("ANY /users/{id}", routeName("any user"))
+	m.Handle("GET /users/{id}", routeName("user"))
+	m.Handle("GET /users/me", routeName("me"))
+	m.Handle("HEAD /users/me", routeName("head me"))
+	m.Handle("GET /files/{id}/meta", routeName("meta"))
+	m.Handle("GET /files/{path+}", routeName("file"))
+	for _, tc := range []struct {
+		method, path, want string
+	}{
+		{"GET", "/users/me", "me"},
+		{"HEAD", "/users/me", "head me"},
+		{"GET", "/users/42", "user"},
+		{"HEAD", "/users/42", "user"},
+		{"POST", "/users/42", "any user"},
+		{"GET", "/users/42/posts", "users rest"},
+		{"DELETE", "/users/42/posts", "any proxy"},
+		{"GET", "/files/1/meta", "meta"},
+		{"GET", "/files/1/data", "file"},
+		{"GET", "/other", "any proxy"},
+	} {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if got := rec.Body.String(); got != tc.want {
+			t.Errorf("%s %s: served by %q, want %q", tc.method, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestMuxMethodNotAllowed(t *testing.T) {
+	m := NewMux()
+	m.Handle("GET /items", routeName("list"))
+	m.Handle("POST /items", routeName("create"))
+	for _, tc := range []struct {
+		method    string
+		wantCode  int
+		wantAllow string
+	}{
+		{"GET", http.StatusOK, ""},
+		{"HEAD", http.StatusOK, ""},
+		{"DELETE", http.StatusMethodNotAllowed, "GET, HEAD, POST"},
+		{"OPTIONS", http.StatusNoContent, "GET, HEAD, OPTIONS, POST"},
+	} {
+		rec := httptest.NewRecorder()
+		m.ServeHTTP(rec, httptest.NewRequest(tc.method, "/items", nil))
+		if rec.Code != tc.wantCode {
+			t.Errorf("%s: got status %d, want %d", tc.method, rec.Code, tc.wantCode)
+		}
+		if got := rec.Header().Get("Allow"); got != tc.wantAllow {
+			t.Errorf("%s: got Allow %q, want %q", tc.method, got, tc.wantAllow)
+		}
+	}
+}
+
+func TestMuxAllowHeader(t *testing.T) {
+	m := NewMux()
+	m.Handle("GET /x", routeName("get"))
+	m.Handle("POST /x", routeName("post"))
+	m.Handle("PUT /y", routeName("put"))
+	for _, tc := range []struct {
+		method, path string
+		wantCode     int
+		wantAllow    string
+	}{
+		{"DELETE", "/x", http.StatusMethodNotAllowed, "GET, HEAD, POST"},
+		{"PATCH", "/x", http.StatusMethodNotAllowed, "GET, HEAD, POST"},
+		{"GET", "/y", http.StatusMethodNotAllowed, "PUT"},
+		{"DELETE", "/z", http.StatusNotFound, ""},
+	} {
+		out := invoke(t, m, testEvent(tc.method, tc.path))
+		if out.StatusCode != tc.wantCode {
+			t.Errorf("%s %s: got status %d, want %d", tc.method, tc.path, out.StatusCode, tc.wantCode)
+		}
+		if got := out.Headers["Allow"]; got != tc.wantAllow {
+			t.Errorf("%s %s: got Allow %q, want %q", tc.method, tc.path, got, tc.wantAllow)
+		}
+	}
+}
+
+func TestMuxDefault(t *testing.T) {
+	for _, tc := range []struct {
+		name, method, path string
+		withDefault        bool
+		wantCode           int
+		wantBody           string
+	}{
+		{"explicit route", "GET", "/orders", true, http.StatusOK, "orders"},
+		{"default", "GET", "/other", true, http.StatusOK, "default"},
+		{"method mismatch goes to default", "DELETE", "/orders", true, http.StatusOK, "default"},
+		{"no default", "GET", "/other", false, http.StatusNotFound, "404 page not found\n"},
+	} {
+		m := NewMux()
+		m.Handle("GET /orders", routeName("orders"))
+		if tc.withDefault {
+			m.SetDefault(routeName("default"))
+		}
+		evt := testEvent(tc.method, tc.path)
+		out := invoke(t, m, evt)
+		if out.StatusCode != tc.wantCode || out.Body != tc.wantBody {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, out.StatusCode, out.Body, tc.wantCode, tc.wantBody)
+		}
+	}
+}
+
+func TestMuxNotFoundHandler(t *testing.T) {
+	nf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"not found"}`)
+	})
+	m := NewMux()
+	m.Handle("GET /items", routeName("items"))
+	for _, tc := range []struct {
+		method, path string
+		wantCode     int
+		wantBody     string
+	}{
+		{"GET", "/items", http.StatusOK, "items"},
+		{"GET", "/missing", http.StatusNotFound, `{"error":"not found"}`},
+		{"POST", "/items", http.StatusMethodNotAllowed, "Method Not Allowed\n"},
+	} {
+		out := invoke(t, m, testEvent(tc.method, tc.path), WithNotFoundHandler(nf))
+		if out.StatusCode != tc.wantCode || out.Body != tc.wantBody {
+			t.Errorf("%s %s: got %d %q, want %d %q", tc.method, tc.path, out.StatusCode, out.Body, tc.wantCode, tc.wantBody)
+		}
+	}
+
+	// default route takes precedence
+	m.SetDefault(routeName("default"))
+	if out := invoke(t, m, testEvent("GET", "/missing"), WithNotFoundHandler(nf)); out.Body != "default" {
+		t.Errorf("with default route: got %d %q", out.StatusCode, out.Body)
+	}
+}
@@ -0,0 +1,438 @@
+package apig
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Option configures the handler returned by Handler.
+type Option func(*config)
+
+type config struct {
+	contextFunc     func(context.Context, *events.APIGatewayV2HTTPRequest) context.Context
+	extraMethods    []string
+	allowedMethods  []string
+	errorLog        *log.Logger
+	debugDump       io.Writer
+	debugDumpLimit  int
+	statusText      func(int) string
+	hostSource      HostSource
+	rawCookies      bool
+	validateCookies bool
+	maxCookieBytes  int
+	reflectHeaders  []string
+	requestID       func() string
+	dateHeader      bool
+	traceHeader     bool
+	clock           func() time.Time
+	conditional     bool
+	ranges          bool
+
+	defaultContentType string
+
+	requestTransform  func(io.Reader) io.Reader
+	responseTransform func([]byte) ([]byte, error)
+
+	base64Encoding *base64.Encoding
+	base64Mode     Base64Mode
+	binaryTypes    []string
+	binaryTypeFunc func(string) bool
+
+	compress        bool
+	compressMinSize int
+	compressTypes   []string
+	encoders        []encoder
+
+	healthPath   string
+	healthStatus int
+	shutdown     <-chan struct{}
+
+	maxConcurrency int
+
+	idempotencyStore IdempotencyStore
+	idempotencyTTL   time.Duration
+
+	basePath           string
+	trimHeaders        bool
+	preserveHeaderCase bool
+	rejectGetBody      bool
+	parseQueryForm     bool
+	unescapePath       bool
+	strictPath         bool
+	maxRequestSize     int64
+	protoMajor         int
+	protoMinor         int
+	maxHeaderCount     int
+	maxHeaderBytes     int
+	maxRespHeaderBytes int
+	maxResponseSize    int
+	oversizeMode       OversizeMode
+	s3API              S3API
+	s3Bucket           string
+	s3TTL              time.Duration
+	failStatus         func(int) bool
+
+	errorPages   map[int]func(*http.Request) (string, []byte)
+	interceptors []func(*http.Response)
+
+	requestInterceptors       []func(context.Context, *events.APIGatewayV2HTTPRequest) error
+	responseEventInterceptors []func(context.Context, *events.APIGatewayV2HTTPResponse)
+
+	notFound      http.Handler
+	panicHandler  func(context.Context, any, []byte) *events.APIGatewayV2HTTPResponse
+	panicStatus   int
+	panicLog      *log.Logger
+	recoverPanics bool
+
+	errorStatusMap  map[error]int
+	errorStatusFunc func(error) int
+
+	managementAPI ManagementAPI
+
+	emfNamespace  string
+	emfOut        io.Writer
+	slowThreshold time.Duration
+	accessLog     *slog.Logger
+
+	jsonMarshal    func(any) ([]byte, error)
+	jsonUnmarshal  func([]byte, any) error
+	omitEmptyBody  bool
+	responseFormat ResponseFormat
+}
+
+func (c *config) logf(format string, args ...any) {
+	if c.errorLog != nil {
+		c.errorLog.Printf(format, args...)
+	}
+}
+
+// WithContextFunc configures a function used to derive the context of the
+// http.Request passed to the handler from the Lambda invocation context and
+// the API Gateway event. It can be used to attach request-scoped values
+// before the handler runs.
+func WithContextFunc(fn func(ctx context.Context, evt *events.APIGatewayV2HTTPRequest) context.Context) Option {
+	return func(c *config) { c.contextFunc = fn }
+}
+
+// WithExtensionMethods registers non-standard HTTP methods (e.g. WebDAV
+// PROPFIND) that are to be matched case-insensitively and passed to the
+// handler in the given form, the same way standard methods are uppercased.
+func WithExtensionMethods(methods ...string) Option {
+	return func(c *config) { c.extraMethods = append(c.extraMethods, methods...) }
+}
+
+// WithErrorLogger configures logger used to report errors and suspicious
+// requests or responses, similar to http.Server.ErrorLog. By default nothing
+// is logged.
+func WithErrorLogger(l *log.Logger) Option {
+	return func(c *config) { c.errorLog = l }
+}
+
+// WithStatusTextFunc overrides the function used to build the status text of
+// ALB responses, which are expected to carry status line such as "200 OK" in
+// their StatusDescription field. By default http.StatusText is used, and
+// codes it does not know get "Status" text.
+func WithStatusTextFunc(fn func(code int) string) Option {
+	return func(c *config) { c.statusText = fn }
+}
+
+// WithHealthCheck configures handler to answer requests with the exact path
+// with the given status code and an empty body, without calling the wrapped
+// http.Handler.
+func WithHealthCheck(path string, status int) Option {
+	return func(c *config) { c.healthPath, c.healthStatus = path, status }
+}
+
+// WithShutdownSignal configures handler to stop passing requests to the
+// wrapped http.Handler once ch is closed, answering them with 503 Service
+// Unavailable and Retry-After header instead. It can be used to drain the
+// function instance when runtime signals it is going to be shut down.
+func WithShutdownSignal(ch <-chan struct{}) Option {
+	return func(c *config) { c.shutdown = ch }
+}
+
+// WithRawCookies disables special handling of cookies by Handler and
+// StreamingHandler. By default cookies from the Cookies field of the event
+// are joined into the Cookie request header, and Set-Cookie response headers
+// are moved to the Cookies field of the response. With this option the
+// Cookies fields of both event and response are ignored, and Set-Cookie
+// headers are returned as regular headers.
+func WithRawCookies() Option {
+	return func(c *config) { c.rawCookies = true }
+}
+
+// WithResponseBase64Encoding configures encoding used for base64-encoded
+// response bodies. API Gateway and Lambda Function URLs expect
+// base64.StdEncoding, which is the default.
+func WithResponseBase64Encoding(enc *base64.Encoding) Option {
+	return func(c *config) { c.base64Encoding = enc }
+}
+
+// WithErrorPages configures bodies of responses with particular status codes,
+// such as 404 or 500, for the cases when the handler responds with such a
+// status and an empty body. Functions in pages map return content type and
+// body for the response. Responses with non-empty bodies are left intact.
+func WithErrorPages(pages map[int]func(*http.Request) (contentType string, body []byte)) Option {
+	return func(c *config) { c.errorPages = pages }
+}
+
+// WithMaxHeaders limits the number of request header values and their total
+// size, counted as the sum of lengths of header names and values. Requests
+// exceeding either limit get 431 Request Header Fields Too Large response
+// without reaching the wrapped http.Handler. Zero value disables the
+// corresponding limit.
+func WithMaxHeaders(count, totalBytes int) Option {
+	return func(c *config) { c.maxHeaderCount, c.maxHeaderBytes = count, totalBytes }
+}
+
+// WithMaxResponseHeaderBytes limits total size of response headers,
+// including cookies, counted as the sum of lengths of header names and
+// values. If handler response exceeds the limit, it is replaced with 500
+// Internal Server Error response, and the error is logged; otherwise API
+// Gateway would reject such response without a clear reason. This option
+// only applies to buffered responses.
+func WithMaxResponseHeaderBytes(n int) Option {
+	return func(c *config) { c.maxRespHeaderBytes = n }
+}
+
+// WithDefaultContentType configures content type of responses for which
+// handler has not set Content-Type header, instead of detecting it with
+// http.DetectContentType. It never overrides content type set by the
+// handler, and is not applied to 204 and 304 responses, or responses handler
+// wrote no data or status code to.
+func WithDefaultContentType(contentType string) Option {
+	return func(c *config) { c.defaultContentType = contentType }
+}
+
+// WithTrimHeaderValues configures handler to remove leading and trailing
+// whitespace from request header values, for the cases when upstream proxies
+// add it and handler's header parsers are strict about it.
+func WithTrimHeaderValues() Option {
+	return func(c *config) { c.trimHeaders = true }
+}
+
+// WithDateHeader configures handler to add Date header with the current
+// time to responses, unless handler has set it itself.
+func WithDateHeader() Option {
+	return func(c *config) { c.dateHeader = true }
+}
+
+// WithClock configures function used to get the current time, such as for
+// the header added by WithDateHeader. It defaults to time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(c *config) { c.clock = now }
+}
+
+func (c *config) now() time.Time {
+	if c.clock != nil {
+		return c.clock()
+	}
+	return time.Now()
+}
+
+// WithRejectBodyOnGet configures handler to respond with 400 Bad Request to
+// GET and HEAD requests that have a non-empty body, without calling the
+// wrapped http.Handler. Semantics of such bodies is undefined, and they are
+// sometimes used in request smuggling attempts.
+func WithRejectBodyOnGet() Option {
+	return func(c *config) { c.rejectGetBody = true }
+}
+
+// WithNotFoundHandler configures handler serving requests that are not
+// matched by any route of a Mux that has no "$default" route, or that have
+// paths outside of the prefix configured with WithBasePath, instead of the
+// plain 404 Not Found response.
+func WithNotFoundHandler(nf http.Handler) Option {
+	return func(c *config) { c.notFound = nf }
+}
+
+// WithProto overrides protocol version reported in http.Request Proto,
+// ProtoMajor and ProtoMinor fields. By default it is taken from the event,
+// if the event reports it, and is HTTP/1.1 otherwise.
+func WithProto(major, minor int) Option {
+	return func(c *config) { c.protoMajor, c.protoMinor = major, minor }
+}
+
+// setProto sets protocol version of r according to the configuration, or from
+// proto reported by the event, if it is valid.
+func (c *config) setProto(r *http.Request, proto string) {
+	if c.protoMajor != 0 {
+		r.ProtoMajor, r.ProtoMinor = c.protoMajor, c.protoMinor
+		r.Proto = "HTTP/" + strconv.Itoa(c.protoMajor) + "." + strconv.Itoa(c.protoMinor)
+		return
+	}
+	if major, minor, ok := http.ParseHTTPVersion(proto); ok {
+		r.ProtoMajor, r.ProtoMinor, r.Proto = major, minor, proto
+	}
+}
+
+// WithBasePath configures handler to remove prefix from request paths, so
+// that r.URL.Path matches routes of the wrapped http.Handler. It is useful
+// with HTTP APIs having named stages, which deliver paths prefixed with the
+// stage name, such as "/prod/users" for the "prod" stage:
+//
+//	apig.WithBasePath("/prod")
+//
+// The prefix is matched by whole path segments: WithBasePath("/prod") strips
+// it from "/prod" and "/prod/users", but not from "/production". Requests
+// with paths that do not start with the prefix get the not found response
+// (see WithNotFoundHandler) instead of reaching the wrapped http.Handler.
+// OriginalPath still returns the path as it was delivered.
+func WithBasePath(prefix string) Option {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return func(c *config) { c.basePath = prefix }
+}
+
+// WithPathUnescaping configures handler to percent-decode request path: by
+// default r.URL.Path is the path delivered in the event as is. With this
+// option r.URL.Path holds the decoded path, and r.URL.RawPath keeps the
+// original one, so r.URL.EscapedPath still returns it. If path has malformed
+// percent-encoding, such as "/a%zzb", in strict mode request gets 400 Bad
+// Request response without reaching the wrapped http.Handler; otherwise the
+// path is logged and passed through undecoded.
+func WithPathUnescaping(strict bool) Option {
+	return func(c *config) { c.unescapePath, c.strictPath = true, strict }
+}
+
+// unescapeRequestPath percent-decodes path of r, as configured with
+// WithPathUnescaping. It returns false if path is malformed and request must
+// be rejected.
+func (c *config) unescapeRequestPath(r *http.Request) bool {
+	p, err := url.PathUnescape(r.URL.Path)
+	if err != nil {
+		if c.strictPath {
+			return false
+		}
+		c.logf("apig: %s %q: malformed path escaping, using path as is: %v", r.Method, r.URL.Path, err)
+		return true
+	}
+	if p != r.URL.Path {
+		r.URL.RawPath, r.URL.Path = r.URL.Path, p
+	}
+	return true
+}
+
+// WithParseQueryForm configures handler to populate r.Form with query string
+// parameters before calling the wrapped http.Handler, so that r.FormValue
+// returns them without reading the request body. Once r.Form is populated,
+// r.ParseForm no longer merges values from the body into it, although it
+// still parses them into r.PostForm.
+func WithParseQueryForm() Option {
+	return func(c *config) { c.parseQueryForm = true }
+}
+
+// WithMaxRequestSize limits size of request bodies to n bytes, after base64
+// decoding. Requests with larger bodies get 413 Request Entity Too Large
+// response without reaching the wrapped http.Handler. Response has a JSON body
+// stating the limit and the actual size:
+//
+//	{"error":"Request Entity Too Large","limit":1024,"size":4096}
+//
+// Use WithErrorPages with an entry for http.StatusRequestEntityTooLarge to
+// send a different body.
+func WithMaxRequestSize(n int64) Option {
+	return func(c *config) { c.maxRequestSize = n }
+}
+
+// requestTooLarge returns response for request r with body exceeding the
+// limit configured with WithMaxRequestSize.
+func (c *config) requestTooLarge(r *http.Request) *response {
+	const code = http.StatusRequestEntityTooLarge
+	res := &response{status: code, header: make(http.Header)}
+	if fn := c.errorPages[code]; fn != nil {
+		ct, body := fn(r)
+		if ct != "" {
+			res.header.Set("Content-Type", ct)
+		}
+		res.body = body
+		return res
+	}
+	res.header.Set("Content-Type", "application/json")
+	res.body, _ = c.marshal(struct {
+		Error string `json:"error"`
+		Limit int64  `json:"limit"`
+		Size  int64  `json:"size"`
+	}{http.StatusText(code), c.maxRequestSize, r.ContentLength})
+	return res
+}
+
+// WithPreserveHeaderCase configures handler to make request headers available
+// in r.Header under the names exactly as they were delivered in the event, in
+// addition to their canonical form: with this option a "sentry-trace" header
+// can be read both with r.Header.Get("Sentry-Trace") and with
+// r.Header["sentry-trace"]. Both keys share the same values.
+//
+// This goes against the assumption http.Header makes that all its keys are
+// canonical: iterating over r.Header yields such headers twice, r.Header.Del
+// only removes the canonical key, and r.Write, httputil.DumpRequest and the
+// like output both. Also note that API Gateway HTTP APIs and Function URLs
+// deliver header names in lowercase, whatever casing client used.
+//
+// Response headers are always sent with the keys they have in the response
+// http.Header map, so handler can send a header with non-canonical name by
+// assigning to the map directly:
+//
+//	w.Header()["sentry-trace"] = []string{v}
+func WithPreserveHeaderCase() Option {
+	return func(c *config) { c.preserveHeaderCase = true }
+}
+
+// addRawHeaderKeys adds keys of r.Header under the names they were delivered
+// in the event, see WithPreserveHeaderCase.
+func addRawHeaderKeys(r *http.Request) {
+	add := func(k string) {
+		ck := http.CanonicalHeaderKey(k)
+		if ck == k {
+			return
+		}
+		if vv, ok := r.Header[ck]; ok {
+			r.Header[k] = vv
+		}
+	}
+	ctx := r.Context()
+	if evt := eventFromContext(ctx); evt != nil {
+		for k := range evt.Headers {
+			add(k)
+		}
+	} else if evt, ok := ctx.Value(v1EventKey).(*events.APIGatewayProxyRequest); ok {
+		for k := range evt.Headers {
+			add(k)
+		}
+		for k := range evt.MultiValueHeaders {
+			add(k)
+		}
+	} else if evt, ok := ctx.Value(albEventKey).(*events.ALBTargetGroupRequest); ok {
+		for k := range evt.Headers {
+			add(k)
+		}
+		for k := range evt.MultiValueHeaders {
+			add(k)
+		}
+	} else if evt, ok := ctx.Value(wsEventKey).(*events.APIGatewayWebsocketProxyRequest); ok {
+		for k := range evt.Headers {
+			add(k)
+		}
+		for k := range evt.MultiValueHeaders {
+			add(k)
+		}
+	} else if evt, ok := ctx.Value(edgeEventKey).(*EdgeEvent); ok {
+		for _, vv := range evt.Records[0].CF.Request.Headers {
+			if len(vv) != 0 && vv[0].Key != "" {
+				add(vv[0].Key)
+			}
+		}
+	}
+}
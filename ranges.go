@@ -0,0 +1,114 @@
+package apig
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// WithRangeRequests enables handling of byte range requests for handlers
+// that do not implement it themselves. If handler responds to a GET request
+// with 200 OK, and request has a Range header with a single byte range,
+// response is replaced with 206 Partial Content with the requested part of
+// the body. Requests with multiple ranges get the whole body, unsatisfiable
+// or malformed ranges get 416 Range Not Satisfiable. If-Range header is
+// honored. Such responses have Accept-Ranges: bytes header.
+//
+// Range requests are only handled for buffered responses.
+func WithRangeRequests() Option {
+	return func(c *config) { c.ranges = true }
+}
+
+// serveRange turns res into a partial response if request r asks for a byte
+// range.
+func serveRange(r *http.Request, res *response) {
+	if r.Method != http.MethodGet || res.status != http.StatusOK ||
+		res.header.Get("Content-Encoding") != "" || res.header.Get("Content-Range") != "" {
+		return
+	}
+	if _, ok := res.header["Accept-Ranges"]; !ok {
+		res.header.Set("Accept-Ranges", "bytes")
+	}
+	spec := r.Header.Get("Range")
+	if spec == "" || !ifRangeMatches(r.Header.Get("If-Range"), res.header) {
+		return
+	}
+	size := len(res.body)
+	start, end, ok := parseRange(spec, size)
+	switch {
+	case !ok:
+		res.status = http.StatusRequestedRangeNotSatisfiable
+		res.header.Set("Content-Range", "bytes */"+strconv.Itoa(size))
+		res.header.Set("Content-Type", "text/plain; charset=utf-8")
+		res.header.Del("Content-Length")
+		res.body = []byte(http.StatusText(res.status) + "\n")
+		res.base64 = nil
+	case start < 0:
+		// multiple ranges, send the whole body
+	default:
+		res.status = http.StatusPartialContent
+		res.header.Set("Content-Range", "bytes "+strconv.Itoa(start)+"-"+strconv.Itoa(end-1)+"/"+strconv.Itoa(size))
+		res.body = res.body[start:end]
+		if res.header.Get("Content-Length") != "" {
+			res.header.Set("Content-Length", strconv.Itoa(len(res.body)))
+		}
+	}
+}
+
+// parseRange parses Range header value spec for content of the given size,
+// returning bounds [start, end) of a single range. For multiple ranges it
+// returns negative start. It reports false if range is malformed or cannot
+// be satisfied.
+func parseRange(spec string, size int) (start, end int, ok bool) {
+	if !strings.HasPrefix(spec, "bytes=") {
+		return 0, 0, false
+	}
+	spec = strings.TrimPrefix(spec, "bytes=")
+	if strings.Contains(spec, ",") {
+		return -1, -1, true
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+	if first == "" {
+		// suffix range: last n bytes
+		n, err := strconv.Atoi(last)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size, true
+	}
+	start, err := strconv.Atoi(first)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+	end = size
+	if last != "" {
+		n, err := strconv.Atoi(last)
+		if err != nil || n < start {
+			return 0, 0, false
+		}
+		if n+1 < end {
+			end = n + 1
+		}
+	}
+	return start, end, true
+}
+
+// ifRangeMatches reports whether If-Range request header value v allows
+// sending a partial response with the given response headers.
+func ifRangeMatches(v string, header http.Header) bool {
+	if v == "" {
+		return true
+	}
+	if strings.HasPrefix(v, `"`) || strings.HasPrefix(v, "W/") {
+		etag := header.Get("ETag")
+		// If-Range requires strong comparison
+		return etag != "" && !strings.HasPrefix(v, "W/") && v == etag
+	}
+	return v == header.Get("Last-Modified")
+}
@@ -0,0 +1,48 @@
+package apig
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestRangeRequests(t *testing.T) {
+	const etag = `"v1"`
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("ETag", etag)
+		io.WriteString(w, "0123456789")
+	})
+	for _, tc := range []struct {
+		name, rangeSpec, ifRange string
+		wantCode                 int
+		wantBody, wantRange      string
+	}{
+		{"no range", "", "", http.StatusOK, "0123456789", ""},
+		{"first bytes", "bytes=0-3", "", http.StatusPartialContent, "0123", "bytes 0-3/10"},
+		{"open ended", "bytes=7-", "", http.StatusPartialContent, "789", "bytes 7-9/10"},
+		{"suffix", "bytes=-2", "", http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{"end past size", "bytes=8-100", "", http.StatusPartialContent, "89", "bytes 8-9/10"},
+		{"multiple ranges", "bytes=0-1,4-5", "", http.StatusOK, "0123456789", ""},
+		{"unsatisfiable", "bytes=20-30", "", http.StatusRequestedRangeNotSatisfiable, "Requested Range Not Satisfiable\n", "bytes */10"},
+		{"malformed", "items=0-1", "", http.StatusRequestedRangeNotSatisfiable, "Requested Range Not Satisfiable\n", "bytes */10"},
+		{"if-range match", "bytes=0-0", etag, http.StatusPartialContent, "0", "bytes 0-0/10"},
+		{"if-range mismatch", "bytes=0-0", `"v2"`, http.StatusOK, "0123456789", ""},
+	} {
+		evt := testEvent("GET", "/")
+		if tc.rangeSpec != "" {
+			evt.Headers["range"] = tc.rangeSpec
+		}
+		if tc.ifRange != "" {
+			evt.Headers["if-range"] = tc.ifRange
+		}
+		out := invoke(t, h, evt, WithRangeRequests())
+		if out.StatusCode != tc.wantCode || out.Body != tc.wantBody || out.Headers["Content-Range"] != tc.wantRange {
+			t.Errorf("%s: got %d %q with Content-Range %q, want %d %q with %q", tc.name,
+				out.StatusCode, out.Body, out.Headers["Content-Range"], tc.wantCode, tc.wantBody, tc.wantRange)
+		}
+		if out.Headers["Accept-Ranges"] != "bytes" {
+			t.Errorf("%s: got Accept-Ranges %q", tc.name, out.Headers["Accept-Ranges"])
+		}
+	}
+}
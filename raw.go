@@ -0,0 +1,202 @@
+package apig
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// NewHandler returns lambda.Handler serving API Gateway HTTP API and Lambda
+// Function URL requests the same way as Handler does. Unlike the function
+// returned by Handler, it works with raw event payloads, so it can be
+// wrapped by middleware operating on lambda.Handler.
+//
+// Event payloads are decoded and responses are encoded with encoding/json by
+// default, see WithJSONCodec to change that.
+//
+// Decorators of the func(lambda.Handler) lambda.Handler form can wrap the
+// result, which then can be started with lambda.StartHandler:
+//
+//	lambda.StartHandler(withMetrics(apig.NewHandler(mux)))
+func NewHandler(h http.Handler, opts ...Option) lambda.Handler {
+	if h == nil {
+		panic("NewHandler called with nil argument")
+	}
+	return rawHandler{newHandler(h, opts)}
+}
+
+// LambdaHandler is the same as NewHandler.
+//
+// Deprecated: use NewHandler.
+func LambdaHandler(h http.Handler, opts ...Option) lambda.Handler {
+	if h == nil {
+		panic("LambdaHandler called with nil argument")
+	}
+	return NewHandler(h, opts...)
+}
+
+type rawHandler struct {
+	*lambdaHandler
+}
+
+func (h rawHandler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	req := new(events.APIGatewayV2HTTPRequest)
+	if err := h.unmarshal(payload, req); err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&invocations, 1)
+	r, res, err := h.newRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = h.serve(r)
+	}
+	defer res.release()
+	return h.encodeResponse(ctx, res, V2)
+}
+
+// DecodeRequest decodes API Gateway HTTP API (or Lambda Function URL) event
+// from its JSON payload, using the JSON codec configured with WithJSONCodec
+// among opts, or encoding/json otherwise. Together with EncodeResponse it can
+// be used by programs that inspect raw payloads before passing events to the
+// function returned by Handler.
+func DecodeRequest(data []byte, opts ...Option) (*events.APIGatewayV2HTTPRequest, error) {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	req := new(events.APIGatewayV2HTTPRequest)
+	if err := c.unmarshal(data, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// EncodeResponse encodes API Gateway HTTP API response to JSON payload, using
+// the JSON codec configured with WithJSONCodec among opts, or encoding/json
+// otherwise. Option configured with WithOmitEmptyBody is honored too.
+func EncodeResponse(res *events.APIGatewayV2HTTPResponse, opts ...Option) ([]byte, error) {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.omitEmptyBody && res.Body == "" {
+		return c.marshal((*responseV2NoBody)(res))
+	}
+	return c.marshal(res)
+}
+
+// encodeResponse converts res to the Lambda response JSON in the configured
+// format, or in the format def if no format is configured.
+func (h *lambdaHandler) encodeResponse(ctx context.Context, res *response, def ResponseFormat) ([]byte, error) {
+	if res.err != nil {
+		return nil, res.err
+	}
+	format := h.responseFormat
+	if format == 0 {
+		format = def
+	}
+	if format == V1 {
+		out := h.responseV1(res)
+		if h.omitEmptyBody && out.Body == "" {
+			return h.marshal((*responseV1NoBody)(out))
+		}
+		return h.marshal(out)
+	}
+	out := h.responseV2(res)
+	if len(h.responseEventInterceptors) != 0 {
+		h.interceptResponseEvent(ctx, out)
+	}
+	if h.omitEmptyBody && out.Body == "" {
+		return h.marshal((*responseV2NoBody)(out))
+	}
+	return h.marshal(out)
+}
+
+// ResponseFormat is the format of the Lambda response.
+type ResponseFormat int
+
+const (
+	// V1 is the API Gateway REST API format, also known as payload format
+	// version 1.0, see events.APIGatewayProxyResponse.
+	V1 ResponseFormat = iota + 1
+	// V2 is the API Gateway HTTP API format, also known as payload format
+	// version 2.0, used by Lambda Function URLs as well, see
+	// events.APIGatewayV2HTTPResponse.
+	V2
+)
+
+// WithResponseFormat configures handlers that work with raw payloads, such as
+// LambdaHandler, to return responses in the given format regardless of the
+// format of the request. By default response format matches that of the
+// request.
+//
+// Response formats differ in how they handle cookies and headers: V2 format
+// returns Set-Cookie headers in a dedicated cookies field, other headers with
+// multiple values are put into multiValueHeaders; V1 format has no cookies
+// field, so Set-Cookie headers are always returned in multiValueHeaders,
+// even if there is only one of them.
+func WithResponseFormat(format ResponseFormat) Option {
+	return func(c *config) { c.responseFormat = format }
+}
+
+// responseV1NoBody has the same fields as events.APIGatewayProxyResponse,
+// but omits the body field from JSON if it is empty.
+type responseV1NoBody struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+	Body              string              `json:"body,omitempty"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded,omitempty"`
+}
+
+// responseV2NoBody has the same fields as events.APIGatewayV2HTTPResponse,
+// but omits the body field from JSON if it is empty.
+type responseV2NoBody struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+	Body              string              `json:"body,omitempty"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded,omitempty"`
+	Cookies           []string            `json:"cookies"`
+}
+
+func (c *config) marshal(v any) ([]byte, error) {
+	if c.jsonMarshal != nil {
+		return c.jsonMarshal(v)
+	}
+	return json.Marshal(v)
+}
+
+func (c *config) unmarshal(data []byte, v any) error {
+	if c.jsonUnmarshal != nil {
+		return c.jsonUnmarshal(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WithJSONCodec configures functions used to decode event payloads and
+// encode responses by handlers that work with raw payloads, such as
+// LambdaHandler. It allows using a faster JSON implementation than
+// encoding/json, which is used by default. It has no effect on function
+// returned by Handler, since lambda package decodes events for it.
+func WithJSONCodec(marshal func(any) ([]byte, error), unmarshal func([]byte, any) error) Option {
+	return func(c *config) { c.jsonMarshal, c.jsonUnmarshal = marshal, unmarshal }
+}
+
+// WithOmitEmptyBody configures handlers that work with raw payloads, such as
+// LambdaHandler, to omit the body field from the response JSON if response
+// body is empty, instead of sending an empty string. Empty bodies are never
+// marked as base64-encoded regardless of this option.
+//
+// This option has no effect on function returned by Handler: lambda package
+// encodes its events.APIGatewayV2HTTPResponse result, and the body field of
+// this type is always present in JSON.
+func WithOmitEmptyBody() Option {
+	return func(c *config) { c.omitEmptyBody = true }
+}
@@ -0,0 +1,255 @@
+package apig
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func TestJSONCodec(t *testing.T) {
+	var marshaled, unmarshaled int
+	marshal := func(v any) ([]byte, error) {
+		marshaled++
+		return json.Marshal(v)
+	}
+	unmarshal := func(data []byte, v any) error {
+		unmarshaled++
+		return json.Unmarshal(data, v)
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "ok") })
+	payload, err := json.Marshal(testEvent("GET", "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auto := AutoHandler(h, WithJSONCodec(marshal, unmarshal))
+	for name, invoke := range map[string]func(context.Context, []byte) ([]byte, error){
+		"NewHandler": NewHandler(h, WithJSONCodec(marshal, unmarshal)).Invoke,
+		"AutoHandler": func(ctx context.Context, payload []byte) ([]byte, error) {
+			return auto(ctx, payload)
+		},
+	} {
+		marshaled, unmarshaled = 0, 0
+		b, err := invoke(context.Background(), payload)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var out events.APIGatewayV2HTTPResponse
+		if err := json.Unmarshal(b, &out); err != nil || out.Body != "ok" {
+			t.Fatalf("%s: got %q, %v", name, b, err)
+		}
+		if marshaled == 0 || unmarshaled == 0 {
+			t.Errorf("%s: codec used for %d marshal and %d unmarshal calls", name, marshaled, unmarshaled)
+		}
+	}
+}
+
+func TestOmitEmptyBody(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		body     string
+		opts     []Option
+		wantBody bool
+	}{
+		{"empty", "", []Option{WithOmitEmptyBody()}, false},
+		{"empty default", "", nil, true},
+		{"non-empty", "ok", []Option{WithOmitEmptyBody()}, true},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, tc.body) })
+		payload, err := json.Marshal(testEvent("GET", "/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := NewHandler(h, tc.opts...).Invoke(context.Background(), payload)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var out map[string]any
+		if err := json.Unmarshal(b, &out); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if out["statusCode"] != float64(http.StatusOK) {
+			t.Errorf("%s: got status %v, want 200", tc.name, out["statusCode"])
+		}
+		if _, ok := out["body"]; ok != tc.wantBody {
+			t.Errorf("%s: got body field present: %v, want %v in %s", tc.name, ok, tc.wantBody, b)
+		}
+		if out["isBase64Encoded"] == true {
+			t.Errorf("%s: got isBase64Encoded set in %s", tc.name, b)
+		}
+	}
+
+	b, err := EncodeResponse(&events.APIGatewayV2HTTPResponse{StatusCode: http.StatusNoContent}, WithOmitEmptyBody())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), `"body"`) {
+		t.Errorf("EncodeResponse: got body field in %s", b)
+	}
+}
+
+func TestResponseFormat(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Set-Cookie", "a=1")
+		io.WriteString(w, "ok")
+	})
+	v2, err := json.Marshal(testEvent("GET", "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, err := json.Marshal(&events.APIGatewayProxyRequest{HTTPMethod: "GET", Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name    string
+		payload []byte
+		opts    []Option
+		wantV1  bool
+	}{
+		{"v2 default", v2, nil, false},
+		{"v1 default", v1, nil, true},
+		{"v2 as V1", v2, []Option{WithResponseFormat(V1)}, true},
+		{"v1 as V2", v1, []Option{WithResponseFormat(V2)}, false},
+	} {
+		b, err := AutoHandler(h, tc.opts...)(context.Background(), tc.payload)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		var out struct {
+			StatusCode        int                 `json:"statusCode"`
+			Body              string              `json:"body"`
+			Cookies           []string            `json:"cookies"`
+			MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+		}
+		if err := json.Unmarshal(b, &out); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if out.StatusCode != http.StatusOK || out.Body != "ok" {
+			t.Errorf("%s: got %d %q", tc.name, out.StatusCode, out.Body)
+		}
+		gotV1 := len(out.Cookies) == 0 && len(out.MultiValueHeaders["Set-Cookie"]) == 1
+		gotV2 := len(out.Cookies) == 1 && out.MultiValueHeaders == nil
+		if gotV1 != tc.wantV1 || gotV2 == tc.wantV1 {
+			t.Errorf("%s: got response in unexpected format: %s", tc.name, b)
+		}
+	}
+}
+
+// withHeader is a decorator adding header to every event passed to next.
+func withHeader(next lambda.Handler, key, value string) lambda.Handler {
+	return lambda.NewHandler(func(ctx context.Context, evt *events.APIGatewayV2HTTPRequest) (json.RawMessage, error) {
+		if evt.Headers == nil {
+			evt.Headers = make(map[string]string)
+		}
+		evt.Headers[key] = value
+		payload, err := json.Marshal(evt)
+		if err != nil {
+			return nil, err
+		}
+		return next.Invoke(ctx, payload)
+	})
+}
+
+func TestNewHandlerDecorator(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Header.Get("X-Decorated"))
+	})
+	payload, err := json.Marshal(testEvent("GET", "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := withHeader(NewHandler(h), "x-decorated", "yes").Invoke(context.Background(), payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out events.APIGatewayV2HTTPResponse
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Body != "yes" {
+		t.Errorf("got body %q, want header added by decorator", out.Body)
+	}
+}
+
+func TestDecodeEncode(t *testing.T) {
+	payload, err := json.Marshal(testEvent("GET", "/peek?x=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded, encoded int
+	codec := WithJSONCodec(
+		func(v any) ([]byte, error) { encoded++; return json.Marshal(v) },
+		func(data []byte, v any) error { decoded++; return json.Unmarshal(data, v) },
+	)
+	req, err := DecodeRequest(payload, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.RawPath != "/peek" || req.RawQueryString != "x=1" || decoded != 1 {
+		t.Errorf("got decoded event %+v after %d codec calls", req, decoded)
+	}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, r.URL.RequestURI()) })
+	res, err := Handler(h)(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := EncodeResponse(res, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out events.APIGatewayV2HTTPResponse
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.StatusCode != http.StatusOK || out.Body != "/peek?x=1" || encoded != 1 {
+		t.Errorf("got encoded response %s after %d codec calls", b, encoded)
+	}
+	if _, err := DecodeRequest([]byte("{")); err == nil {
+		t.Error("DecodeRequest of malformed payload: got nil error")
+	}
+}
+
+// countingHandler is a lambda.Handler decorator counting invocations.
+type countingHandler struct {
+	next  lambda.Handler
+	calls int
+}
+
+func (h *countingHandler) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	h.calls++
+	return h.next.Invoke(ctx, payload)
+}
+
+func TestNewHandler(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, r.Method+" "+r.URL.RequestURI())
+	})
+	for name, lh := range map[string]lambda.Handler{
+		"NewHandler":    NewHandler(h),
+		"LambdaHandler": LambdaHandler(h),
+	} {
+		wrapped := &countingHandler{next: lh}
+		payload, err := json.Marshal(testEvent("PUT", "/items/1?x=y"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := wrapped.Invoke(context.Background(), payload)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var out events.APIGatewayV2HTTPResponse
+		if err := json.Unmarshal(b, &out); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if out.StatusCode != http.StatusOK || out.Body != "PUT /items/1?x=y" || wrapped.calls != 1 {
+			t.Errorf("%s: got %d %q after %d calls", name, out.StatusCode, out.Body, wrapped.calls)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ServeToRecorder converts evt to http.Request, serves it with h the same way
+// Handler does, and returns the response recorded, instead of converting it
+// to the API Gateway format. This allows custom pipelines to inspect and
+// modify the response, or to cache it, before converting it with NewResponse.
+//
+// Recorded response is the one Handler would send, with all the options
+// applied. If response body is explicitly marked for base64 encoding or
+// against it, recorder has Base64Header set, which NewResponse honors.
+func ServeToRecorder(h http.Handler, evt *events.APIGatewayV2HTTPRequest, opts ...Option) (*httptest.ResponseRecorder, error) {
+	if h == nil {
+		panic("ServeToRecorder called with nil argument")
+	}
+	hh := newHandler(h, opts)
+	r, res, err := hh.newRequest(context.Background(), evt)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = hh.serve(r)
+	}
+	defer res.release()
+	if res.err != nil {
+		return nil, res.err
+	}
+	rec := httptest.NewRecorder()
+	for k, vv := range res.header {
+		rec.Header()[k] = append([]string(nil), vv...)
+	}
+	if res.base64 != nil {
+		rec.Header().Set(Base64Header, strconv.FormatBool(*res.base64))
+	}
+	rec.WriteHeader(res.status)
+	rec.Write(res.body)
+	return rec, nil
+}
+
+// NewResponse converts response recorded by rec to the API Gateway HTTP API
+// response format the same way Handler does, using opts affecting the
+// conversion, such as WithRawCookies, WithBinaryContentTypes or
+// WithResponseBase64Encoding. Unlike Handler, it does not otherwise process
+// the response: for example, it does not set Content-Type if it is missing.
+func NewResponse(rec *httptest.ResponseRecorder, opts ...Option) *events.APIGatewayV2HTTPResponse {
+	h := newHandler(http.NotFoundHandler(), opts)
+	header := rec.Header().Clone()
+	res := &response{status: rec.Code, header: header}
+	if rec.Body != nil {
+		res.body = rec.Body.Bytes()
+	}
+	if v := header.Get(Base64Header); v != "" {
+		header.Del(Base64Header)
+		if b, err := strconv.ParseBool(v); err == nil {
+			res.base64 = &b
+		}
+	}
+	return h.responseV2(res)
+}
@@ -0,0 +1,49 @@
+package apig
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestServeToRecorder(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Set-Cookie", "b=2")
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "hello "+r.URL.Path)
+	})
+	rec, err := ServeToRecorder(h, testEvent("GET", "/world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusAccepted || rec.Body.String() != "hello /world" ||
+		rec.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("got %d %q with Content-Type %q", rec.Code, rec.Body, rec.Header().Get("Content-Type"))
+	}
+
+	// response can be modified before conversion
+	rec.Header().Set("X-Signature", "sig")
+	out := NewResponse(rec)
+	if out.StatusCode != http.StatusAccepted || out.Body != "hello /world" || out.Headers["X-Signature"] != "sig" {
+		t.Errorf("NewResponse: got %d %q with headers %v", out.StatusCode, out.Body, out.Headers)
+	}
+	if want := []string{"a=1", "b=2"}; !reflect.DeepEqual(out.Cookies, want) {
+		t.Errorf("NewResponse: got cookies %q, want %q", out.Cookies, want)
+	}
+
+	// explicit base64 marker survives the round-trip
+	h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(Base64Header, "true")
+		io.WriteString(w, "text")
+	})
+	if rec, err = ServeToRecorder(h, testEvent("GET", "/")); err != nil {
+		t.Fatal(err)
+	}
+	out = NewResponse(rec)
+	if !out.IsBase64Encoded || out.Body != "dGV4dA==" || out.Headers[Base64Header] != "" {
+		t.Errorf("base64: got body %q, base64 %v, headers %v", out.Body, out.IsBase64Encoded, out.Headers)
+	}
+}
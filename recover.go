@@ -0,0 +1,134 @@
+package apig
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// WithPanicHandler enables recovery from panics in the wrapped http.Handler.
+// When handler panics, fn is called with the recovered value and the stack
+// trace, and the response it returns is sent to the client. If fn returns
+// nil, a plain 500 Internal Server Error response (see also WithPanicStatus)
+// is sent. Context passed to fn is derived from the request context, and
+// also provides recovered value and stack trace with RecoveredPanic.
+//
+// Panic recovery only applies to buffered responses.
+func WithPanicHandler(fn func(ctx context.Context, recovered any, stack []byte) *events.APIGatewayV2HTTPResponse) Option {
+	return func(c *config) { c.panicHandler = fn }
+}
+
+// WithPanicStatus enables recovery from panics in the wrapped http.Handler,
+// responding with a plain text response with the given status code instead
+// of 500 Internal Server Error. If used together with WithPanicHandler, code
+// is used when the panic handler returns nil. With StreamingHandler, it sets
+// the status sent if handler panics before writing the response headers.
+// WithPanicStatus panics if code is not in 5xx range.
+func WithPanicStatus(code int) Option {
+	if code < 500 || code > 599 {
+		panic("apig: invalid panic status " + strconv.Itoa(code))
+	}
+	return func(c *config) { c.panicStatus = code }
+}
+
+// WithRecovery enables recovery from panics in the wrapped http.Handler:
+// when handler panics, a plain 500 Internal Server Error response is sent
+// (see also WithPanicStatus and WithPanicHandler), instead of failing the
+// Lambda invocation, which ends up in an opaque 502 response from API Gateway
+// and a restart of the runtime. Panic value and stack trace are logged to l,
+// or to the logger configured with WithErrorLogger if l is nil.
+func WithRecovery(l *log.Logger) Option {
+	return func(c *config) { c.recoverPanics, c.panicLog = true, l }
+}
+
+// panicf logs handler panic.
+func (c *config) panicf(format string, args ...any) {
+	if c.panicLog != nil {
+		c.panicLog.Printf(format, args...)
+		return
+	}
+	c.logf(format, args...)
+}
+
+// recovers reports whether handler panics are to be recovered.
+func (c *config) recovers() bool {
+	return c.recoverPanics || c.panicHandler != nil || c.panicStatus != 0
+}
+
+// recoveryStatus returns status code of the response sent when handler
+// panics.
+func (c *config) recoveryStatus() int {
+	if c.panicStatus != 0 {
+		return c.panicStatus
+	}
+	return http.StatusInternalServerError
+}
+
+type panicInfo struct {
+	value any
+	stack []byte
+}
+
+// RecoveredPanic returns value and stack trace of the panic recovered from
+// the handler, if ctx is the one passed to the function configured with
+// WithPanicHandler.
+func RecoveredPanic(ctx context.Context) (recovered any, stack []byte, ok bool) {
+	if p, ok := ctx.Value(panicKey).(*panicInfo); ok {
+		return p.value, p.stack, true
+	}
+	return nil, nil, false
+}
+
+// serveRecover calls handler recovering from its panics. If handler panics,
+// it returns response to send instead of what handler might have written.
+func (h *lambdaHandler) serveRecover(w http.ResponseWriter, r *http.Request) (res *response) {
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+		stack := debug.Stack()
+		if p != http.ErrAbortHandler {
+			h.panicf("apig: %s %s: panic serving request: %v\n%s", r.Method, r.URL.Path, p, stack)
+		}
+		if h.panicHandler != nil {
+			ctx := context.WithValue(r.Context(), panicKey, &panicInfo{value: p, stack: stack})
+			if out := h.panicHandler(ctx, p, stack); out != nil {
+				res = fromV2(out)
+				return
+			}
+		}
+		res = errorResponse(h.recoveryStatus())
+	}()
+	h.handler.ServeHTTP(w, r)
+	return nil
+}
+
+// fromV2 converts API Gateway HTTP API response to response.
+func fromV2(out *events.APIGatewayV2HTTPResponse) *response {
+	res := &response{status: out.StatusCode, header: make(http.Header, len(out.Headers))}
+	for k, v := range out.Headers {
+		res.header.Set(k, v)
+	}
+	for k, vv := range out.MultiValueHeaders {
+		res.header[http.CanonicalHeaderKey(k)] = vv
+	}
+	for _, v := range out.Cookies {
+		res.header.Add("Set-Cookie", v)
+	}
+	res.body = []byte(out.Body)
+	if out.IsBase64Encoded {
+		if b, err := base64.StdEncoding.DecodeString(out.Body); err == nil {
+			res.body = b
+		}
+	}
+	if res.status == 0 {
+		res.status = http.StatusOK
+	}
+	return res
+}
@@ -0,0 +1,143 @@
+package apig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var errValidation = errors.New("validation failed")
+
+func TestPanicHandler(t *testing.T) {
+	var ctxValue any
+	fn := func(ctx context.Context, recovered any, stack []byte) *events.APIGatewayV2HTTPResponse {
+		ctxValue, _, _ = RecoveredPanic(ctx)
+		if err, ok := recovered.(error); ok && errors.Is(err, errValidation) {
+			return &events.APIGatewayV2HTTPResponse{
+				StatusCode: http.StatusUnprocessableEntity,
+				Headers:    map[string]string{"Content-Type": "application/json"},
+				Body:       `{"error":"validation failed"}`,
+			}
+		}
+		return nil
+	}
+	for _, tc := range []struct {
+		name     string
+		value    any
+		wantCode int
+		wantBody string
+	}{
+		{"mapped", errValidation, http.StatusUnprocessableEntity, `{"error":"validation failed"}`},
+		{"fallback", "boom", http.StatusInternalServerError, "Internal Server Error\n"},
+	} {
+		ctxValue = nil
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(tc.value) })
+		out := invoke(t, h, testEvent("GET", "/"), WithPanicHandler(fn))
+		if out.StatusCode != tc.wantCode || out.Body != tc.wantBody {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, out.StatusCode, out.Body, tc.wantCode, tc.wantBody)
+		}
+		if ctxValue != tc.value {
+			t.Errorf("%s: RecoveredPanic returned %v, want %v", tc.name, ctxValue, tc.value)
+		}
+	}
+	if _, _, ok := RecoveredPanic(context.Background()); ok {
+		t.Error("RecoveredPanic outside of panic handler: got ok")
+	}
+}
+
+func TestPanicStatus(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Partial", "1")
+		panic("boom")
+	})
+	for _, tc := range []struct {
+		opts     []Option
+		wantCode int
+	}{
+		{[]Option{WithPanicHandler(func(context.Context, any, []byte) *events.APIGatewayV2HTTPResponse { return nil })},
+			http.StatusInternalServerError},
+		{[]Option{WithPanicStatus(http.StatusBadGateway)}, http.StatusBadGateway},
+		{[]Option{WithPanicStatus(599)}, 599},
+	} {
+		out := invoke(t, h, testEvent("GET", "/"), tc.opts...)
+		if out.StatusCode != tc.wantCode {
+			t.Errorf("got status %d, want %d", out.StatusCode, tc.wantCode)
+		}
+		if _, ok := out.Headers["X-Partial"]; ok {
+			t.Error("headers set before panic leaked into response")
+		}
+	}
+
+	// streaming handler sends configured status if handler panics before
+	// writing headers
+	out, err := StreamingHandler(h, WithPanicStatus(http.StatusServiceUnavailable))(context.Background(), testEvent("GET", "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("streaming: got status %d, want %d", out.StatusCode, http.StatusServiceUnavailable)
+	}
+
+	for _, code := range []int{200, 404, 600} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithPanicStatus(%d) did not panic", code)
+				}
+			}()
+			WithPanicStatus(code)
+		}()
+	}
+}
+
+func TestRecovery(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/abort" {
+			panic(http.ErrAbortHandler)
+		}
+		panic("boom")
+	})
+	var panicLog, errorLog bytes.Buffer
+	for _, tc := range []struct {
+		name, path string
+		l          *log.Logger
+		wantPanic  bool
+		wantError  bool
+	}{
+		{"own logger", "/x", log.New(&panicLog, "", 0), true, false},
+		{"error logger", "/x", nil, false, true},
+		{"abort", "/abort", log.New(&panicLog, "", 0), false, false},
+	} {
+		panicLog.Reset()
+		errorLog.Reset()
+		out := invoke(t, h, testEvent("GET", tc.path), WithRecovery(tc.l), WithErrorLogger(log.New(&errorLog, "", 0)))
+		if out.StatusCode != http.StatusInternalServerError {
+			t.Errorf("%s: got status %d", tc.name, out.StatusCode)
+		}
+		for _, l := range []struct {
+			name string
+			buf  *bytes.Buffer
+			want bool
+		}{{"panic", &panicLog, tc.wantPanic}, {"error", &errorLog, tc.wantError}} {
+			s := l.buf.String()
+			logged := strings.Contains(s, "GET /x: panic serving request: boom\n") && strings.Contains(s, "goroutine ")
+			if logged != l.want || (!l.want && s != "") {
+				t.Errorf("%s: got %s log %q", tc.name, l.name, s)
+			}
+		}
+	}
+
+	// without recovery panic propagates to the Lambda runtime
+	defer func() {
+		if recover() == nil {
+			t.Error("handler panic was recovered without WithRecovery")
+		}
+	}()
+	Handler(h)(context.Background(), testEvent("GET", "/"))
+}
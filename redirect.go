@@ -0,0 +1,48 @@
+package apig
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Redirect returns a handler that responds to every request with a redirect
+// to location, using the given 3xx status code. Unlike http.RedirectHandler,
+// response has an empty body. Redirect panics if code is not in 3xx range.
+func Redirect(location string, code int) http.Handler {
+	if code < 300 || code > 399 {
+		panic("apig: invalid redirect code " + strconv.Itoa(code))
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Location", location)
+		w.WriteHeader(code)
+	})
+}
+
+// RequireHTTPS returns a handler that redirects requests made over plain
+// HTTP to the same URL with https scheme using 308 Permanent Redirect, and
+// otherwise calls h, adding Strict-Transport-Security header with a one year
+// max-age to its responses, unless h sets it itself.
+//
+// Request scheme is taken from the X-Forwarded-Proto header, as set by
+// API Gateway, ALB, or CloudFront; requests without it are considered to be
+// made over HTTPS, since Lambda Function URLs only accept HTTPS.
+func RequireHTTPS(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+		if strings.EqualFold(strings.TrimSpace(proto), "http") && r.Host != "" {
+			// r.RequestURI keeps the base path and escaping client used
+			uri := r.RequestURI
+			if uri == "" {
+				uri = r.URL.RequestURI()
+			}
+			w.Header().Set("Location", "https://"+r.Host+uri)
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		if _, ok := w.Header()["Strict-Transport-Security"]; !ok {
+			w.Header().Set("Strict-Transport-Security", "max-age=31536000")
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,57 @@
+package apig
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	for _, code := range []int{http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect} {
+		out := invoke(t, Redirect("https://example.com/target", code), testEvent("GET", "/go"))
+		if out.StatusCode != code || out.Headers["Location"] != "https://example.com/target" {
+			t.Errorf("code %d: got %d with Location %q", code, out.StatusCode, out.Headers["Location"])
+		}
+		if out.Body != "" || out.IsBase64Encoded {
+			t.Errorf("code %d: got body %q, base64 %v, want empty body", code, out.Body, out.IsBase64Encoded)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Redirect with code 200 did not panic")
+		}
+	}()
+	Redirect("/", http.StatusOK)
+}
+
+func TestRequireHTTPS(t *testing.T) {
+	h := RequireHTTPS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/own" {
+			w.Header().Set("Strict-Transport-Security", "max-age=60")
+		}
+	}))
+	for _, tc := range []struct {
+		name, target, proto string
+		code                int
+		location, hsts      string
+	}{
+		{"http", "/a/b?x=1", "http", http.StatusPermanentRedirect,
+			"https://example.lambda-url.us-east-1.on.aws/a/b?x=1", ""},
+		{"escaped", "/a%2Fb", "http", http.StatusPermanentRedirect,
+			"https://example.lambda-url.us-east-1.on.aws/a%2Fb", ""},
+		{"https", "/a/b", "https", http.StatusOK, "", "max-age=31536000"},
+		{"no header", "/a/b", "", http.StatusOK, "", "max-age=31536000"},
+		{"handler hsts", "/own", "https", http.StatusOK, "", "max-age=60"},
+	} {
+		evt := testEvent("GET", tc.target)
+		if tc.proto != "" {
+			evt.Headers["x-forwarded-proto"] = tc.proto
+		}
+		out := invoke(t, h, evt)
+		if out.StatusCode != tc.code || out.Headers["Location"] != tc.location ||
+			out.Headers["Strict-Transport-Security"] != tc.hsts {
+			t.Errorf("%s: got %d with Location %q, HSTS %q, want %d with %q, %q", tc.name,
+				out.StatusCode, out.Headers["Location"], out.Headers["Strict-Transport-Security"],
+				tc.code, tc.location, tc.hsts)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package apig
+
+import "net/http"
+
+// WithReflectHeaders configures handler to copy request headers with the
+// given names to the response, unless handler has already set them itself.
+// Headers missing in the request are not added. This can be used to echo
+// tracing headers like X-Correlation-Id back to the client without handler
+// cooperation.
+func WithReflectHeaders(names ...string) Option {
+	return func(c *config) {
+		for _, name := range names {
+			c.reflectHeaders = append(c.reflectHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+}
+
+// reflectRequestHeaders copies request headers configured with WithReflectHeaders
+// to the response header.
+func (h *lambdaHandler) reflectRequestHeaders(r *http.Request, header http.Header) {
+	for _, k := range h.reflectHeaders {
+		if _, ok := header[k]; ok {
+			continue
+		}
+		if vv := r.Header.Values(k); len(vv) != 0 {
+			header[k] = append([]string(nil), vv...)
+		}
+	}
+}
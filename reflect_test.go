@@ -0,0 +1,34 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestReflectHeaders(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Trace-Id", "from-handler")
+	})
+	evt := testEvent("GET", "/")
+	evt.Headers["x-correlation-id"] = "abc"
+	evt.Headers["x-trace-id"] = "from-request"
+	opts := []Option{WithReflectHeaders("x-correlation-id", "X-Trace-Id", "X-Missing")}
+	want := map[string]string{"X-Correlation-Id": "abc", "X-Trace-Id": "from-handler", "X-Missing": ""}
+
+	out := invoke(t, h, evt, opts...)
+	for k, v := range want {
+		if got := out.Headers[k]; got != v {
+			t.Errorf("got %s %q, want %q", k, got, v)
+		}
+	}
+	sout, err := StreamingHandler(h, opts...)(context.Background(), evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range want {
+		if got := sout.Headers[k]; got != v {
+			t.Errorf("streaming: got %s %q, want %q", k, got, v)
+		}
+	}
+}
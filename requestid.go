@@ -0,0 +1,60 @@
+package apig
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// WithRequestIDGenerator configures function used to generate request ID for
+// requests whose events carry no ID assigned by API Gateway, such as ALB
+// requests or hand-crafted events in tests. Generated ID is available with
+// RequestID; IDs delivered in events are never overridden. If fn is nil, IDs
+// are random UUIDs.
+func WithRequestIDGenerator(fn func() string) Option {
+	if fn == nil {
+		fn = randomUUID
+	}
+	return func(c *config) { c.requestID = fn }
+}
+
+// RequestID returns ID of the request: the one API Gateway assigned to it
+// (see GatewayRequestID), or the one generated by the function configured
+// with WithRequestIDGenerator. It returns an empty string if ID is not known.
+func RequestID(ctx context.Context) string {
+	if id := GatewayRequestID(ctx); id != "" {
+		return id
+	}
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// withRequestID returns ctx with a generated request ID stored in it, if
+// event ID is empty and ID generator is configured.
+func (c *config) withRequestID(ctx context.Context, eventID string) context.Context {
+	if eventID != "" || c.requestID == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey, c.requestID())
+}
+
+// randomUUID returns a random (version 4) UUID.
+func randomUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
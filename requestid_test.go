@@ -0,0 +1,33 @@
+package apig
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+func TestRequestIDGenerator(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for _, tc := range []struct {
+		name, eventID string
+		opts          []Option
+		want          *regexp.Regexp
+	}{
+		{"no generator", "", nil, regexp.MustCompile(`^$`)},
+		{"event ID kept", "gw-1", []Option{WithRequestIDGenerator(func() string { return "generated" })}, regexp.MustCompile(`^gw-1$`)},
+		{"generated", "", []Option{WithRequestIDGenerator(func() string { return "generated" })}, regexp.MustCompile(`^generated$`)},
+		{"default generator", "", []Option{WithRequestIDGenerator(nil)}, uuid},
+	} {
+		var got string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = RequestID(r.Context()) })
+		evt := testEvent("GET", "/")
+		evt.RequestContext.RequestID = tc.eventID
+		invoke(t, h, evt, tc.opts...)
+		if !tc.want.MatchString(got) {
+			t.Errorf("%s: got request ID %q, want match for %s", tc.name, got, tc.want)
+		}
+	}
+	if a, b := randomUUID(), randomUUID(); a == b {
+		t.Errorf("randomUUID returned %q twice", a)
+	}
+}
@@ -0,0 +1,195 @@
+package apig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3API is a subset of Amazon S3 API used to offload oversized responses,
+// see WithS3Overflow.
+//
+// By default a built-in implementation is used, which calls the API over
+// HTTPS signing requests with credentials from the environment, that Lambda
+// runtime provides. Implement this interface over an AWS SDK client to use
+// SDK instead.
+type S3API interface {
+	// PutObject stores body as the object with the given key. Header holds
+	// Content-Type and other content headers of the response, which
+	// object should be served with.
+	PutObject(ctx context.Context, bucket, key string, body []byte, header http.Header) error
+	// PresignGetObject returns URL which allows getting the object without
+	// credentials for ttl.
+	PresignGetObject(ctx context.Context, bucket, key string, ttl time.Duration) (string, error)
+}
+
+// WithS3Overflow configures handler to offload buffered 200 OK responses
+// exceeding the size limit (see WithMaxResponseSize; without it, it is
+// MaxPayloadSize) to the S3 bucket: such response body is stored as an
+// object, and client is redirected to its presigned URL valid for ttl with a
+// 303 See Other response, which keeps Set-Cookie headers of the original
+// response. If api is nil, built-in implementation is used, see S3API.
+//
+// Objects are stored with random keys under the "apig-overflow/" prefix, and
+// are never deleted by the handler: configure bucket lifecycle rule to
+// expire them. Note that URLs presigned with temporary credentials, such as
+// ones of Lambda execution role, stop working once credentials expire, even
+// if ttl has not passed yet. If upload fails, error is logged, and response
+// is handled according to the mode of WithMaxResponseSize.
+func WithS3Overflow(api S3API, bucket string, ttl time.Duration) Option {
+	if api == nil {
+		api = sigV4S3API{client: http.DefaultClient}
+	}
+	return func(c *config) {
+		c.s3API, c.s3Bucket, c.s3TTL = api, bucket, ttl
+		if c.maxResponseSize == 0 {
+			c.maxResponseSize = MaxPayloadSize
+		}
+	}
+}
+
+// s3Headers are response headers stored with offloaded objects.
+var s3Headers = []string{"Cache-Control", "Content-Disposition", "Content-Encoding", "Content-Language", "Content-Type"}
+
+// offloadResponse stores body of response res to S3, returning redirect to
+// send instead, or nil if response cannot be offloaded.
+func (h *lambdaHandler) offloadResponse(r *http.Request, res *response) *response {
+	if res.status != http.StatusOK {
+		return nil
+	}
+	header := make(http.Header, len(s3Headers))
+	for _, k := range s3Headers {
+		if vv := res.header[k]; len(vv) != 0 {
+			header[k] = vv
+		}
+	}
+	ctx := r.Context()
+	key := "apig-overflow/" + randomUUID()
+	if err := h.s3API.PutObject(ctx, h.s3Bucket, key, res.body, header); err != nil {
+		h.logf("apig: %s %s: offloading response to S3: %v", r.Method, r.URL.Path, err)
+		return nil
+	}
+	u, err := h.s3API.PresignGetObject(ctx, h.s3Bucket, key, h.s3TTL)
+	if err != nil {
+		h.logf("apig: %s %s: presigning offloaded response URL: %v", r.Method, r.URL.Path, err)
+		return nil
+	}
+	out := &response{status: http.StatusSeeOther, header: make(http.Header)}
+	out.header.Set("Location", u)
+	out.header.Set("Cache-Control", "no-store")
+	for k, vv := range res.header {
+		if strings.EqualFold(k, "Set-Cookie") {
+			out.header[k] = vv
+		}
+	}
+	return out
+}
+
+// sigV4S3API implements S3API over plain HTTP, signing requests with AWS
+// Signature Version 4 using credentials from the environment. Object keys
+// must only have unreserved characters and slashes, since S3 does not
+// normalize paths the way signV4 expects.
+type sigV4S3API struct {
+	client *http.Client
+}
+
+// objectURL returns virtual-hosted-style URL of the object.
+func (sigV4S3API) objectURL(bucket, key string) string {
+	return "https://" + bucket + ".s3." + os.Getenv("AWS_REGION") + ".amazonaws.com/" + key
+}
+
+func (api sigV4S3API) PutObject(ctx context.Context, bucket, key string, body []byte, header http.Header) error {
+	u := api.objectURL(bucket, key)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, vv := range header {
+		req.Header[k] = vv
+	}
+	sum := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(sum[:]))
+	if err := signV4(req, body, "s3", time.Now()); err != nil {
+		return err
+	}
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("apig: PUT %s: %s: %s", u, resp.Status, bytes.TrimSpace(msg))
+}
+
+func (api sigV4S3API) PresignGetObject(_ context.Context, bucket, key string, ttl time.Duration) (string, error) {
+	return presignV4(api.objectURL(bucket, key), "s3", ttl, time.Now())
+}
+
+// presignV4 returns rawURL presigned for GET requests with AWS Signature
+// Version 4 using credentials and region from the environment.
+func presignV4(rawURL, service string, ttl time.Duration, now time.Time) (string, error) {
+	keyID, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	region := os.Getenv("AWS_REGION")
+	if keyID == "" || secret == "" || region == "" {
+		return "", errors.New("apig: AWS credentials or region not found in the environment")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	params := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    keyID + "/" + scope,
+		"X-Amz-Date":          amzDate,
+		"X-Amz-Expires":       strconv.Itoa(int(ttl / time.Second)),
+		"X-Amz-SignedHeaders": "host",
+	}
+	if token := os.Getenv("AWS_SESSION_TOKEN"); token != "" {
+		params["X-Amz-Security-Token"] = token
+	}
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var query strings.Builder
+	for _, k := range keys {
+		if query.Len() != 0 {
+			query.WriteByte('&')
+		}
+		query.WriteString(sigV4Escape(k) + "=" + sigV4Escape(params[k]))
+	}
+	canonRequest := strings.Join([]string{
+		http.MethodGet,
+		u.EscapedPath(),
+		query.String(),
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	canonHash := sha256.Sum256([]byte(canonRequest))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonHash[:])
+	key := []byte("AWS4" + secret)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, s)
+	}
+	u.RawQuery = query.String() + "&X-Amz-Signature=" + hex.EncodeToString(hmacSHA256(key, toSign))
+	return u.String(), nil
+}
@@ -0,0 +1,48 @@
+package apig
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	})
+	for _, tc := range []struct {
+		name string
+		cfg  SecurityHeadersConfig
+		want map[string]string
+	}{
+		{"defaults", SecurityHeadersConfig{}, map[string]string{
+			"X-Content-Type-Options":    "nosniff",
+			"X-Frame-Options":           "SAMEORIGIN",
+			"Referrer-Policy":           "strict-origin-when-cross-origin",
+			"Strict-Transport-Security": "max-age=31536000",
+			"Content-Security-Policy":   "",
+		}},
+		{"configured", SecurityHeadersConfig{
+			FrameOptions:          "DENY",
+			ReferrerPolicy:        "no-referrer",
+			HSTSMaxAge:            time.Hour,
+			HSTSIncludeSubdomains: true,
+			ContentSecurityPolicy: "default-src 'self'",
+		}, map[string]string{
+			"X-Frame-Options":           "SAMEORIGIN",
+			"Referrer-Policy":           "no-referrer",
+			"Strict-Transport-Security": "max-age=3600; includeSubDomains",
+			"Content-Security-Policy":   "default-src 'self'",
+		}},
+		{"no hsts", SecurityHeadersConfig{HSTSMaxAge: -1}, map[string]string{
+			"Strict-Transport-Security": "",
+		}},
+	} {
+		out := invoke(t, h, testEvent("GET", "/"), WithSecurityHeaders(tc.cfg))
+		for k, want := range tc.want {
+			if got := out.Headers[k]; got != want {
+				t.Errorf("%s: got %s %q, want %q", tc.name, k, got, want)
+			}
+		}
+	}
+}
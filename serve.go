@@ -0,0 +1,98 @@
+package apig
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/artyom/apig/internal/httpevent"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Serve starts the Lambda runtime loop serving API Gateway HTTP API and Lambda
+// Function URL requests with h, the same way as
+//
+//	lambda.Start(apig.Handler(h, opts...))
+//
+// does, but with the defaults suitable for most programs applied first, so
+// that opts can override them: handler recovers from panics, responding with
+// 500 Internal Server Error (see WithPanicStatus), and requests whose events
+// carry no ID get a random one (see WithRequestIDGenerator and RequestID).
+// As with Handler, r.RemoteAddr holds the client IP address.
+// Serve does not return.
+func Serve(h http.Handler, opts ...Option) {
+	lambda.Start(Handler(h, serveOptions(opts)...))
+}
+
+// serveOptions returns opts with the defaults used by Serve prepended.
+func serveOptions(opts []Option) []Option {
+	return append([]Option{
+		WithPanicStatus(http.StatusInternalServerError),
+		WithRequestIDGenerator(nil),
+	}, opts...)
+}
+
+// Start serves requests with h as Serve does when running inside AWS Lambda,
+// which it detects by the AWS_LAMBDA_RUNTIME_API environment variable.
+// Otherwise it starts a local HTTP server, so that the same program can be
+// run for development: server listens on the port from the PORT environment
+// variable, or on 8080, on the loopback interface. Each request is converted
+// to a Function URL event, as apigtest.EventFromRequest does, and served
+// through the same handler Serve uses, so that opts apply as they would in
+// Lambda; invocation errors are logged and reported with 502 Bad Gateway
+// responses, like Function URLs do. Start does not return.
+func Start(h http.Handler, opts ...Option) {
+	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+		Serve(h, opts...)
+		return
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	addr := net.JoinHostPort("localhost", port)
+	log.Printf("apig: not running in AWS Lambda, serving on http://%s", addr)
+	log.Fatal(http.ListenAndServe(addr, localHandler(Handler(h, serveOptions(opts)...))))
+}
+
+// localHandler returns http.Handler serving requests with fn, as if they
+// were delivered by a Function URL.
+func localHandler(fn func(context.Context, *events.APIGatewayV2HTTPRequest) (*events.APIGatewayV2HTTPResponse, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out, err := fn(r.Context(), httpevent.FromRequest(r))
+		if err != nil {
+			log.Printf("apig: %s %s: %v", r.Method, r.URL.Path, err)
+			http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+			return
+		}
+		body := []byte(out.Body)
+		if out.IsBase64Encoded {
+			if body, err = base64.StdEncoding.DecodeString(out.Body); err != nil {
+				log.Printf("apig: %s %s: decoding response body: %v", r.Method, r.URL.Path, err)
+				http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+				return
+			}
+		}
+		for k, v := range out.Headers {
+			w.Header().Add(k, v)
+		}
+		for k, vv := range out.MultiValueHeaders {
+			for _, v := range vv {
+				w.Header().Add(k, v)
+			}
+		}
+		for _, c := range out.Cookies {
+			w.Header().Add("Set-Cookie", c)
+		}
+		code := out.StatusCode
+		if code == 0 {
+			code = http.StatusOK
+		}
+		w.WriteHeader(code)
+		w.Write(body)
+	})
+}
@@ -0,0 +1,84 @@
+package apig
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestServeDefaults(t *testing.T) {
+	var id, addr string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, addr = RequestID(r.Context()), r.RemoteAddr
+		if r.URL.Path == "/panic" {
+			panic("boom")
+		}
+	})
+	for _, tc := range []struct {
+		name, target string
+		opts         []Option
+		code         int
+	}{
+		{"ok", "/", nil, http.StatusOK},
+		{"recovered", "/panic", nil, http.StatusInternalServerError},
+		{"overridden", "/panic", []Option{WithPanicStatus(http.StatusServiceUnavailable)}, http.StatusServiceUnavailable},
+	} {
+		id, addr = "", ""
+		out := invoke(t, h, testEvent("GET", tc.target), serveOptions(tc.opts)...)
+		if out.StatusCode != tc.code {
+			t.Errorf("%s: got status %d, want %d", tc.name, out.StatusCode, tc.code)
+		}
+		if id == "" || addr != "192.0.2.1" {
+			t.Errorf("%s: got request ID %q, RemoteAddr %q", tc.name, id, addr)
+		}
+	}
+}
+
+func TestLocalHandler(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			Fail(r, errors.New("failed"))
+			return
+		}
+		b, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Add("Set-Cookie", "a=1")
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Origin")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(append([]byte{0xff}, b...))
+	})
+	srv := httptest.NewServer(localHandler(Handler(h, serveOptions(nil)...)))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/echo?x=1", "text/plain", strings.NewReader("hi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || string(b) != "\xffhi" {
+		t.Errorf("got %d %q", resp.StatusCode, b)
+	}
+	if resp.Header.Get("Set-Cookie") != "a=1" || !reflect.DeepEqual(resp.Header.Values("Vary"), []string{"Accept", "Origin"}) {
+		t.Errorf("got headers %v", resp.Header)
+	}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+	if resp, err = http.Get(srv.URL + "/fail"); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway || !strings.Contains(buf.String(), "GET /fail: ") {
+		t.Errorf("invocation error: got status %d, log %q", resp.StatusCode, buf.String())
+	}
+}
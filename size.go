@@ -0,0 +1,101 @@
+package apig
+
+import (
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+)
+
+// MaxPayloadSize is the maximum size of the synchronous Lambda invocation
+// response, which includes buffered responses sent to API Gateway, Function
+// URLs and ALB.
+const MaxPayloadSize = 6 << 20
+
+// OversizeMode is the way to handle responses exceeding the size limit, see
+// WithMaxResponseSize.
+type OversizeMode int
+
+const (
+	// OversizeError makes Lambda invocation fail with an error describing
+	// the response, instead of sending it. API Gateway then responds with
+	// its own 5xx error.
+	OversizeError OversizeMode = iota
+	// OversizeStatus replaces the response with a plain 413 Request Entity
+	// Too Large response.
+	OversizeStatus
+	// OversizeTruncate truncates response body to fit the limit. Bodies
+	// with Content-Encoding, such as compressed ones, cannot be truncated,
+	// so such responses are handled as with OversizeStatus.
+	OversizeTruncate
+)
+
+// WithMaxResponseSize limits size of buffered responses to n bytes, or to
+// MaxPayloadSize if n is zero. Without it, a response that is too large
+// makes the Lambda runtime fail with an error that tells little about the
+// cause. Size is estimated as the size of the body, after base64 encoding if
+// it is base64-encoded, plus size of the headers, so n should leave some room
+// for the JSON encoding overhead. Responses over the limit are handled
+// according to mode, unless they are offloaded with WithS3Overflow, and are
+// logged.
+//
+// The limit does not apply to StreamingHandler; streamed responses are not
+// subject to the buffered response size limit.
+func WithMaxResponseSize(n int, mode OversizeMode) Option {
+	if n == 0 {
+		n = MaxPayloadSize
+	}
+	return func(c *config) { c.maxResponseSize, c.oversizeMode = n, mode }
+}
+
+// limitResponseSize handles response res served for request r if it exceeds
+// the limit configured with WithMaxResponseSize, returning response to send
+// instead.
+func (h *lambdaHandler) limitResponseSize(r *http.Request, res *response) *response {
+	var hsize int
+	for k, vv := range res.header {
+		for _, v := range vv {
+			hsize += len(k) + len(v)
+		}
+	}
+	bsize := len(res.body)
+	encode, _ := h.base64Body(res)
+	if encode {
+		bsize = h.base64Enc().EncodedLen(bsize)
+	}
+	if hsize+bsize <= h.maxResponseSize {
+		return res
+	}
+	h.logf("apig: %s %s: response of %d bytes (%d of them headers) exceeds size limit of %d bytes",
+		r.Method, r.URL.Path, hsize+bsize, hsize, h.maxResponseSize)
+	if h.s3API != nil {
+		if out := h.offloadResponse(r, res); out != nil {
+			return out
+		}
+	}
+	mode := h.oversizeMode
+	if mode == OversizeTruncate && res.header.Get("Content-Encoding") != "" {
+		// truncated encoded body would be corrupt
+		mode = OversizeStatus
+	}
+	switch mode {
+	case OversizeStatus:
+		return errorResponse(http.StatusRequestEntityTooLarge)
+	case OversizeTruncate:
+		n := h.maxResponseSize - hsize
+		if encode {
+			n = n / 4 * 3
+		}
+		if n < 0 {
+			n = 0
+		}
+		// do not leave a partial UTF-8 sequence at the end of text body
+		for !encode && n > 0 && !utf8.RuneStart(res.body[n]) {
+			n--
+		}
+		res.body = res.body[:n]
+		res.header.Del("Content-Length")
+		return res
+	}
+	return &response{err: fmt.Errorf("apig: %s %s: response of %d bytes with %d status exceeds size limit of %d bytes",
+		r.Method, r.URL.Path, hsize+bsize, res.status, h.maxResponseSize)}
+}
@@ -0,0 +1,59 @@
+package apig
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMaxResponseSize(t *testing.T) {
+	// with Content-Type set, headers take 22 bytes ("Content-Type" and
+	// "text/plain"), or 21 for "image/png"
+	for _, tc := range []struct {
+		name, contentType, body string
+		limit                   int
+		mode                    OversizeMode
+		code                    int
+		want                    string
+	}{
+		{"within limit", "text/plain", strings.Repeat("a", 50), 72, OversizeError, http.StatusOK, strings.Repeat("a", 50)},
+		{"status", "text/plain", strings.Repeat("a", 51), 72, OversizeStatus, http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge)},
+		{"truncated", "text/plain", strings.Repeat("a", 100), 72, OversizeTruncate, http.StatusOK, strings.Repeat("a", 50)},
+		{"truncated at rune", "text/plain", strings.Repeat("é", 40), 73, OversizeTruncate, http.StatusOK, strings.Repeat("é", 25)},
+		{"truncated binary", "image/png", strings.Repeat("\x00", 40), 51, OversizeTruncate, http.StatusOK,
+			base64.StdEncoding.EncodeToString(make([]byte, 21))},
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", tc.contentType)
+			io.WriteString(w, tc.body)
+		})
+		var buf bytes.Buffer
+		out := invoke(t, h, testEvent("GET", "/"), WithMaxResponseSize(tc.limit, tc.mode),
+			WithErrorLogger(log.New(&buf, "", 0)))
+		if out.StatusCode != tc.code || strings.TrimSpace(out.Body) != tc.want {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, out.StatusCode, out.Body, tc.code, tc.want)
+		}
+		if logged := strings.Contains(buf.String(), "exceeds size limit"); logged != (tc.name != "within limit") {
+			t.Errorf("%s: got log %q", tc.name, buf.String())
+		}
+	}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, strings.Repeat("a", 100)) })
+	evt := testEvent("GET", "/")
+	evt.Headers["accept-encoding"] = "gzip"
+	out := invoke(t, h, evt, WithCompression(0), WithMaxResponseSize(32, OversizeTruncate),
+		WithErrorLogger(log.New(io.Discard, "", 0)))
+	if out.StatusCode != http.StatusRequestEntityTooLarge || out.Headers["Content-Encoding"] != "" {
+		t.Errorf("OversizeTruncate of encoded body: got %d with Content-Encoding %q",
+			out.StatusCode, out.Headers["Content-Encoding"])
+	}
+	_, err := Handler(h, WithMaxResponseSize(64, OversizeError))(context.Background(), testEvent("GET", "/big"))
+	if err == nil || !strings.Contains(err.Error(), "GET /big: response of") {
+		t.Errorf("OversizeError: got error %v", err)
+	}
+}
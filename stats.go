@@ -0,0 +1,11 @@
+package apig
+
+import "sync/atomic"
+
+// invocations counts requests received by all handlers of this package.
+var invocations uint64
+
+// InvocationCount returns the number of requests received by handlers of
+// this package in this function instance. Value of 1 inside a handler means
+// the request is the first one served by the instance, i.e. a cold start.
+func InvocationCount() uint64 { return atomic.LoadUint64(&invocations) }
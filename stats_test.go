@@ -0,0 +1,38 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestInvocationCount(t *testing.T) {
+	var seen []uint64
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = append(seen, InvocationCount()) })
+	start := InvocationCount()
+	for i := 0; i < 3; i++ {
+		invoke(t, h, testEvent("GET", "/"))
+	}
+	for i, n := range seen {
+		if want := start + uint64(i) + 1; n != want {
+			t.Errorf("invocation %d: got count %d, want %d", i, n, want)
+		}
+	}
+
+	// counter must be race-free; run with -race
+	start = InvocationCount()
+	var wg sync.WaitGroup
+	fn := Handler(http.NotFoundHandler())
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(context.Background(), testEvent("GET", "/"))
+		}()
+	}
+	wg.Wait()
+	if got := InvocationCount() - start; got != 10 {
+		t.Errorf("got %d concurrent invocations counted, want 10", got)
+	}
+}
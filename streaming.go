@@ -0,0 +1,226 @@
+package apig
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// StreamingHandler returns function suitable to use as an AWS Lambda handler
+// with github.com/aws/aws-lambda-go/lambda package for Lambda Function URLs
+// configured with RESPONSE_STREAM invoke mode.
+//
+// Unlike with Handler, response is not buffered, but sent to the client as
+// the handler writes it, so it is not subject to the buffered response size
+// limit. http.ResponseWriter passed to the handler implements http.Flusher:
+// writes are buffered in chunks of a few kilobytes, so handlers sending small
+// pieces of data that should reach the client promptly, such as server-sent
+// events, need to call Flush after each of them.
+// Status and headers are sent on the first call to WriteHeader, Write, or
+// Flush; headers modified after that are ignored, the same way net/http
+// server does. When the handler returns, any buffered data is flushed and the
+// stream is closed, including the case when the handler panics. Panics are
+// only recovered if configured to (see WithRecovery); otherwise, as handler
+// runs in its own goroutine, the panic crashes the function. Response
+// trailers are not supported by the streaming response format, and are
+// discarded.
+//
+// If compression is enabled with WithCompression, responses of compressible
+// types are gzip-compressed as they are streamed, regardless of the minimum
+// size, because it is not known upfront; each Flush call flushes the
+// compressor as well.
+//
+// Status, headers and cookies are sent in the JSON prelude of the Function URL
+// streaming protocol, followed by the 8 null bytes separator and the body;
+// this encoding is done by events.LambdaFunctionURLStreamingResponse.
+//
+// Note that streaming responses require function to be built with the
+// lambda.norpc build tag, or to use the provided runtime.
+func StreamingHandler(h http.Handler, opts ...Option) func(context.Context, *events.APIGatewayV2HTTPRequest) (*events.LambdaFunctionURLStreamingResponse, error) {
+	if h == nil {
+		panic("StreamingHandler called with nil argument")
+	}
+	return newHandler(h, opts).runStreaming
+}
+
+func (h *lambdaHandler) runStreaming(ctx context.Context, req *events.APIGatewayV2HTTPRequest) (*events.LambdaFunctionURLStreamingResponse, error) {
+	atomic.AddUint64(&invocations, 1)
+	r, res, err := h.newRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = h.precheck(r)
+	}
+	if res == nil {
+		// released once handler returns
+		res = h.acquire()
+	}
+	if res != nil {
+		out := &events.LambdaFunctionURLStreamingResponse{StatusCode: res.status, Body: bytes.NewReader(res.body)}
+		out.Headers, out.Cookies = h.streamingHeaders(res.header)
+		return out, nil
+	}
+	if h.debugDump != nil {
+		h.dumpRequest(r)
+	}
+	pr, pw := io.Pipe()
+	w := &streamWriter{
+		header: make(http.Header),
+		buf:    bufio.NewWriter(pw),
+		ready:  make(chan struct{}),
+	}
+	if h.compress && r.Method != http.MethodHead && acceptsEncoding(r.Header, "gzip") {
+		w.compressTypes = h.compressTypes
+		if w.compressTypes == nil {
+			w.compressTypes = defaultCompressibleTypes
+		}
+	}
+	// handler may modify the request while the response is being
+	// prepared, so response headers taken from it are collected upfront
+	fromRequest := make(http.Header)
+	if len(h.reflectHeaders) != 0 {
+		h.reflectRequestHeaders(r, fromRequest)
+	}
+	if h.traceHeader {
+		setTraceHeader(r, fromRequest)
+	}
+	var start time.Time
+	if h.timed() {
+		start = time.Now()
+	}
+	go func() {
+		defer func() {
+			var p any
+			if h.recovers() {
+				p = recover()
+			}
+			if p != nil && !w.wroteHeader {
+				w.WriteHeader(h.recoveryStatus())
+			}
+			// emit status and headers if handler wrote nothing
+			w.WriteHeader(http.StatusOK)
+			var err error
+			if w.zw != nil {
+				err = w.zw.Close()
+			}
+			if ferr := w.buf.Flush(); err == nil {
+				err = ferr
+			}
+			if p != nil {
+				if p != http.ErrAbortHandler {
+					h.panicf("apig: %s %s: panic serving request: %v", r.Method, r.URL.Path, p)
+				}
+				err = fmt.Errorf("apig: handler panic: %v", p)
+			}
+			pw.CloseWithError(err)
+			h.release()
+			if h.timed() {
+				h.served(r, w.status, w.written, time.Since(start))
+			}
+		}()
+		h.handler.ServeHTTP(w, r)
+	}()
+	<-w.ready
+	for k, vv := range fromRequest {
+		if _, ok := w.sentHeader[k]; !ok {
+			w.sentHeader[k] = vv
+		}
+	}
+	if h.dateHeader && w.sentHeader.Get("Date") == "" {
+		w.sentHeader.Set("Date", h.now().UTC().Format(http.TimeFormat))
+	}
+	out := &events.LambdaFunctionURLStreamingResponse{StatusCode: w.status, Body: pr}
+	out.Headers, out.Cookies = h.streamingHeaders(w.sentHeader)
+	return out, nil
+}
+
+// streamingHeaders converts headers to the form used by streaming response
+// prelude.
+func (h *lambdaHandler) streamingHeaders(header http.Header) (map[string]string, []string) {
+	var cookies []string
+	headers := make(map[string]string, len(header))
+	for _, k := range sortedKeys(header) {
+		vv := header[k]
+		if !h.rawCookies && strings.EqualFold(k, "Set-Cookie") {
+			cookies = append(cookies, vv...)
+			continue
+		}
+		headers[k] = strings.Join(vv, ", ")
+	}
+	return headers, cookies
+}
+
+// streamWriter is an http.ResponseWriter used by StreamingHandler.
+type streamWriter struct {
+	header      http.Header
+	buf         *bufio.Writer
+	wroteHeader bool
+
+	compressTypes []string     // if set, response of these types is gzipped
+	zw            *gzip.Writer // set if response is gzipped
+	written       int          // number of body bytes written by handler
+
+	ready      chan struct{} // closed once status and headers are known
+	status     int
+	sentHeader http.Header
+}
+
+func (w *streamWriter) Header() http.Header { return w.header }
+
+func (w *streamWriter) WriteHeader(code int) {
+	if w.wroteHeader || code < 200 {
+		return
+	}
+	w.wroteHeader = true
+	w.status = code
+	w.sentHeader = w.header.Clone()
+	// response is streamed, its length is not known upfront
+	w.sentHeader.Del("Transfer-Encoding")
+	// streamed body is never base64-encoded
+	w.sentHeader.Del(Base64Header)
+	// streaming response format has no place for trailers
+	w.sentHeader.Del("Trailer")
+	if w.compressTypes != nil && code != http.StatusNoContent &&
+		code != http.StatusNotModified && code != http.StatusPartialContent &&
+		w.sentHeader.Get("Content-Encoding") == "" &&
+		matchContentType(w.compressTypes, w.sentHeader.Get("Content-Type")) {
+		w.sentHeader.Set("Content-Encoding", "gzip")
+		w.sentHeader.Add("Vary", "Accept-Encoding")
+		w.sentHeader.Del("Content-Length")
+		w.zw = gzip.NewWriter(w.buf)
+	}
+	close(w.ready)
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.header.Get("Content-Type") == "" && len(p) != 0 {
+			w.header.Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	w.written += len(p)
+	if w.zw != nil {
+		return w.zw.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+// Flush sends any buffered data to the client.
+func (w *streamWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+	if w.zw != nil {
+		w.zw.Flush()
+	}
+	w.buf.Flush()
+}
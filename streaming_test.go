@@ -0,0 +1,192 @@
+package apig
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStreamingEmptyResponse(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		handler  http.HandlerFunc
+		wantCode int
+		wantErr  bool
+	}{
+		{"nothing", func(w http.ResponseWriter, r *http.Request) {}, http.StatusOK, false},
+		{"status only", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusAccepted) }, http.StatusAccepted, false},
+		{"flush only", func(w http.ResponseWriter, r *http.Request) { w.(http.Flusher).Flush() }, http.StatusOK, false},
+		{"panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") }, http.StatusInternalServerError, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := StreamingHandler(tc.handler, WithRecovery(log.New(io.Discard, "", 0)))(context.Background(), testEvent("GET", "/"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.StatusCode != tc.wantCode {
+				t.Errorf("got status %d, want %d", out.StatusCode, tc.wantCode)
+			}
+			b, err := io.ReadAll(out.Body)
+			if len(b) != 0 {
+				t.Errorf("got body %q, want empty", b)
+			}
+			if (err != nil) != tc.wantErr {
+				t.Errorf("got body read error %v, want error: %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestStreamingReflectHeaders(t *testing.T) {
+	done := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		w.Header().Set("X-Handler", "1")
+		w.WriteHeader(http.StatusOK)
+		// request is modified while response headers are being prepared
+		r.Header.Set("X-Correlation-Id", "changed")
+		r.Header.Set("X-Late", "1")
+	})
+	evt := testEvent("GET", "/")
+	evt.Headers["x-correlation-id"] = "abc"
+	out, err := StreamingHandler(h, WithReflectHeaders("X-Correlation-Id", "X-Handler", "X-Late"))(context.Background(), evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, out.Body)
+	<-done
+	for k, want := range map[string]string{"X-Correlation-Id": "abc", "X-Handler": "1", "X-Late": ""} {
+		if got := out.Headers[k]; got != want {
+			t.Errorf("got %s %q, want %q", k, got, want)
+		}
+	}
+}
+
+func TestStreamingGzip(t *testing.T) {
+	chunks := []string{"data: one\n\n", "data: two\n\n", "data: three\n\n"}
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range chunks {
+			io.WriteString(w, c)
+			w.(http.Flusher).Flush()
+		}
+	})
+	for _, tc := range []struct {
+		name, accept string
+		wantGzip     bool
+	}{
+		{"gzip", "gzip", true},
+		{"identity", "identity", false},
+	} {
+		evt := testEvent("GET", "/events")
+		evt.Headers["accept-encoding"] = tc.accept
+		out, err := StreamingHandler(h, WithCompression(0))(context.Background(), evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body io.Reader = out.Body
+		if gotGzip := out.Headers["Content-Encoding"] == "gzip"; gotGzip != tc.wantGzip {
+			t.Fatalf("%s: got Content-Encoding %q", tc.name, out.Headers["Content-Encoding"])
+		}
+		if tc.wantGzip {
+			zr, err := gzip.NewReader(out.Body)
+			if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+			body = zr
+		}
+		b, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if want := strings.Join(chunks, ""); string(b) != want {
+			t.Errorf("%s: got body %q, want %q", tc.name, b, want)
+		}
+	}
+}
+
+func TestStreamingPrelude(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Origin")
+		http.SetCookie(w, &http.Cookie{Name: "a", Value: "1"})
+		http.SetCookie(w, &http.Cookie{Name: "b", Value: "2"})
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "body")
+		// headers changed after they were sent are ignored
+		w.Header().Set("X-Late", "1")
+	})
+	out, err := StreamingHandler(h)(context.Background(), testEvent("GET", "/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := out.ContentType(); ct != "application/vnd.awslambda.http-integration-response" {
+		t.Errorf("got content type %q", ct)
+	}
+	raw, err := io.ReadAll(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prelude, body, ok := bytes.Cut(raw, make([]byte, 8))
+	if !ok {
+		t.Fatalf("no separator in %q", raw)
+	}
+	var p struct {
+		StatusCode int               `json:"statusCode"`
+		Headers    map[string]string `json:"headers"`
+		Cookies    []string          `json:"cookies"`
+	}
+	if err := json.Unmarshal(prelude, &p); err != nil {
+		t.Fatalf("%v: %q", err, prelude)
+	}
+	if p.StatusCode != http.StatusCreated || string(body) != "body" {
+		t.Errorf("got status %d, body %q", p.StatusCode, body)
+	}
+	if p.Headers["Content-Type"] != "text/plain" || p.Headers["Vary"] != "Accept, Origin" {
+		t.Errorf("got headers %v", p.Headers)
+	}
+	if _, ok := p.Headers["X-Late"]; ok {
+		t.Error("header set after body was written is in prelude")
+	}
+	if strings.Join(p.Cookies, "; ") != "a=1; b=2" {
+		t.Errorf("got cookies %q", p.Cookies)
+	}
+}
+
+func TestStreamingFlush(t *testing.T) {
+	next := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Add("Set-Cookie", "a=1")
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "data: one\n\n")
+		w.(http.Flusher).Flush()
+		<-next
+		io.WriteString(w, "data: two\n\n")
+	})
+	out, err := StreamingHandler(h)(context.Background(), testEvent("GET", "/events"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.StatusCode != http.StatusAccepted || out.Headers["Content-Type"] != "text/event-stream" ||
+		len(out.Cookies) != 1 || out.Cookies[0] != "a=1" {
+		t.Errorf("got %d with headers %v, cookies %q", out.StatusCode, out.Headers, out.Cookies)
+	}
+	// first event must arrive before handler writes the second one
+	first := make([]byte, len("data: one\n\n"))
+	if _, err := io.ReadFull(out.Body, first); err != nil || string(first) != "data: one\n\n" {
+		t.Fatalf("got first chunk %q, error %v", first, err)
+	}
+	close(next)
+	rest, err := io.ReadAll(out.Body)
+	if err != nil || string(rest) != "data: two\n\n" {
+		t.Errorf("got rest of body %q, error %v", rest, err)
+	}
+}
@@ -0,0 +1,73 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"os"
+)
+
+// traceInfo holds tracing context of a request.
+type traceInfo struct {
+	xray   string // X-Ray trace header
+	parent string // W3C traceparent header
+}
+
+// withTrace returns ctx with tracing context of the request attached. X-Ray
+// trace header of the Lambda invocation is preferred over the one in request
+// headers, since it is the one the function segments belong to.
+func withTrace(ctx context.Context, headers http.Header) context.Context {
+	var t traceInfo
+	// set by github.com/aws/aws-lambda-go/lambda package
+	if v, ok := ctx.Value("x-amzn-trace-id").(string); ok && v != "" {
+		t.xray = v
+	} else if v := headers.Get("X-Amzn-Trace-Id"); v != "" {
+		t.xray = v
+	} else {
+		t.xray = os.Getenv("_X_AMZN_TRACE_ID")
+	}
+	t.parent = headers.Get("Traceparent")
+	if t == (traceInfo{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, traceKey, &t)
+}
+
+// TraceID returns AWS X-Ray trace header of the request, of the form
+// "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1",
+// which allows X-Ray instrumentation of the handler to link its segments to
+// the Lambda invocation. The header is taken from the Lambda invocation, or
+// from the X-Amzn-Trace-Id request header if invocation does not provide
+// one. It returns an empty string if trace is not known.
+func TraceID(ctx context.Context) string {
+	if t, ok := ctx.Value(traceKey).(*traceInfo); ok {
+		return t.xray
+	}
+	return ""
+}
+
+// TraceParent returns W3C Trace Context traceparent header of the request,
+// as used by OpenTelemetry, or an empty string if request has none.
+func TraceParent(ctx context.Context) string {
+	if t, ok := ctx.Value(traceKey).(*traceInfo); ok {
+		return t.parent
+	}
+	return ""
+}
+
+// WithTraceHeader configures handler to set X-Amzn-Trace-Id response header
+// to the trace header of the request (see TraceID), unless handler sets it,
+// so that clients can correlate responses with traces.
+func WithTraceHeader() Option {
+	return func(c *config) { c.traceHeader = true }
+}
+
+// setTraceHeader sets trace response header for request r, as configured by
+// WithTraceHeader.
+func setTraceHeader(r *http.Request, header http.Header) {
+	if header.Get("X-Amzn-Trace-Id") != "" {
+		return
+	}
+	if v := TraceID(r.Context()); v != "" {
+		header.Set("X-Amzn-Trace-Id", v)
+	}
+}
@@ -0,0 +1,58 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestTraceID(t *testing.T) {
+	const (
+		invocation = "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"
+		header     = "Root=1-67891233-abcdef012345678912345678"
+		env        = "Root=1-11111111-222222222222222222222222"
+		parent     = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	)
+	var xray, tp string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		xray, tp = TraceID(r.Context()), TraceParent(r.Context())
+		if r.URL.Path == "/own" {
+			w.Header().Set("X-Amzn-Trace-Id", "own")
+		}
+	})
+	for _, tc := range []struct {
+		name, path            string
+		ctxValue, hdr, envVar string
+		want, wantResponse    string
+	}{
+		{"none", "/", "", "", "", "", ""},
+		{"invocation", "/", invocation, header, env, invocation, invocation},
+		{"header", "/", "", header, env, header, header},
+		{"environment", "/", "", "", env, env, env},
+		{"handler header", "/own", invocation, "", "", invocation, "own"},
+	} {
+		t.Setenv("_X_AMZN_TRACE_ID", tc.envVar)
+		ctx := context.Background()
+		if tc.ctxValue != "" {
+			ctx = context.WithValue(ctx, "x-amzn-trace-id", tc.ctxValue)
+		}
+		evt := testEvent("GET", tc.path)
+		if tc.hdr != "" {
+			evt.Headers["x-amzn-trace-id"] = tc.hdr
+		}
+		evt.Headers["traceparent"] = parent
+		out, err := Handler(h, WithTraceHeader())(ctx, evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if xray != tc.want || tp != parent {
+			t.Errorf("%s: got trace ID %q, traceparent %q, want %q", tc.name, xray, tp, tc.want)
+		}
+		if got := out.Headers["X-Amzn-Trace-Id"]; got != tc.wantResponse {
+			t.Errorf("%s: got response trace header %q, want %q", tc.name, got, tc.wantResponse)
+		}
+	}
+	if TraceID(context.Background()) != "" || TraceParent(context.Background()) != "" {
+		t.Error("outside of request: got non-empty trace")
+	}
+}
@@ -0,0 +1,55 @@
+package apig
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// WithRequestBodyTransformer configures function wrapping request body
+// before it is passed to the handler, such as for transparent decryption.
+// Since transformed body size is not known upfront, r.ContentLength is set
+// to -1 for requests with non-empty bodies.
+func WithRequestBodyTransformer(fn func(io.Reader) io.Reader) Option {
+	return func(c *config) { c.requestTransform = fn }
+}
+
+// WithResponseBodyTransformer configures function transforming response
+// body before it is converted to the Lambda response, such as for
+// transparent encryption. It is called after response compression, if one is
+// enabled, and before deciding whether the body needs base64 encoding. If fn
+// returns an error, it is logged, and 500 Internal Server Error is sent
+// instead.
+//
+// Response transformer only applies to buffered responses.
+func WithResponseBodyTransformer(fn func([]byte) ([]byte, error)) Option {
+	return func(c *config) { c.responseTransform = fn }
+}
+
+// transformRequest wraps body of r with the configured transformer.
+func (h *lambdaHandler) transformRequest(r *http.Request) {
+	if r.ContentLength == 0 {
+		return
+	}
+	body := r.Body
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{h.requestTransform(body), body}
+	r.ContentLength = -1
+}
+
+// transformResponse replaces body of res with the result of the configured
+// transformer.
+func (h *lambdaHandler) transformResponse(r *http.Request, res *response) {
+	b, err := h.responseTransform(res.body)
+	if err != nil {
+		h.logf("apig: %s %s: transforming response body: %v", r.Method, r.URL.Path, err)
+		*res = *errorResponse(http.StatusInternalServerError)
+		return
+	}
+	res.body = b
+	if res.header.Get("Content-Length") != "" {
+		res.header.Set("Content-Length", strconv.Itoa(len(b)))
+	}
+}
@@ -0,0 +1,54 @@
+package apig
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// rot13 is a trivial reversible transformation standing in for encryption.
+func rot13(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z':
+			c = 'a' + (c-'a'+13)%26
+		case c >= 'A' && c <= 'Z':
+			c = 'A' + (c-'A'+13)%26
+		}
+		out[i] = c
+	}
+	return out
+}
+
+func TestBodyTransformers(t *testing.T) {
+	var got string
+	var contentLength int64
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got, contentLength = string(b), r.ContentLength
+		w.Header().Set("Content-Length", "5")
+		io.WriteString(w, "reply")
+	})
+	decrypt := func(r io.Reader) io.Reader {
+		b, _ := io.ReadAll(r)
+		return bytes.NewReader(rot13(b))
+	}
+	encrypt := func(b []byte) ([]byte, error) { return append(rot13(b), '!'), nil }
+	evt := testEvent("POST", "/")
+	evt.Body = "frperg"
+	out := invoke(t, h, evt, WithRequestBodyTransformer(decrypt), WithResponseBodyTransformer(encrypt))
+	if got != "secret" || contentLength != -1 {
+		t.Errorf("handler got body %q with ContentLength %d, want %q with -1", got, contentLength, "secret")
+	}
+	if out.Body != "ercyl!" || out.Headers["Content-Length"] != "6" {
+		t.Errorf("got response body %q with Content-Length %q", out.Body, out.Headers["Content-Length"])
+	}
+
+	failing := func([]byte) ([]byte, error) { return nil, errors.New("no key") }
+	if out := invoke(t, h, evt, WithResponseBodyTransformer(failing)); out.StatusCode != http.StatusInternalServerError {
+		t.Errorf("failing transformer: got status %d, want 500", out.StatusCode)
+	}
+}
@@ -0,0 +1,113 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandlerV1 returns function suitable to use as an AWS Lambda handler with
+// github.com/aws/aws-lambda-go/lambda package for API Gateway REST API
+// routes with Lambda proxy integration (payload format 1.0).
+//
+// Request headers are taken from the multi-value headers of the event, if
+// present, and from the single-value ones otherwise. REST API delivers query
+// parameters already decoded, so r.URL.RawQuery is built by encoding them
+// back, and parameter order may differ from the one client used. Response
+// is converted as described for WithResponseFormat with V1 format. Path
+// parameters are available with r.PathValue, as with Handler.
+//
+// Note that both request and response are fully cached in memory.
+func HandlerV1(h http.Handler, opts ...Option) func(context.Context, *events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if h == nil {
+		panic("HandlerV1 called with nil argument")
+	}
+	return newHandler(h, opts).runV1
+}
+
+func (h *lambdaHandler) runV1(ctx context.Context, req *events.APIGatewayProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	atomic.AddUint64(&invocations, 1)
+	r, res := h.newRequestV1(ctx, req)
+	if res == nil {
+		res = h.serve(r)
+	}
+	defer res.release()
+	if res.err != nil {
+		return nil, res.err
+	}
+	return h.responseV1(res), nil
+}
+
+// newRequestV1 converts API Gateway REST API event to http.Request. If
+// request cannot be created, it returns response that should be sent
+// instead.
+func (h *lambdaHandler) newRequestV1(ctx context.Context, req *events.APIGatewayProxyRequest) (*http.Request, *response) {
+	method, ok := normalizeMethod(req.HTTPMethod, h.extraMethods)
+	if !ok {
+		return nil, errorResponse(http.StatusBadRequest)
+	}
+	var headers http.Header
+	if req.MultiValueHeaders != nil {
+		headers = make(http.Header, len(req.MultiValueHeaders))
+		for k, vv := range req.MultiValueHeaders {
+			k = http.CanonicalHeaderKey(k)
+			headers[k] = append(headers[k], vv...)
+		}
+	} else {
+		headers = singleValueHeaders(req.Headers)
+	}
+	var query url.Values
+	if req.MultiValueQueryStringParameters != nil {
+		query = url.Values(req.MultiValueQueryStringParameters)
+	} else {
+		query = make(url.Values, len(req.QueryStringParameters))
+		for k, v := range req.QueryStringParameters {
+			query.Set(k, v)
+		}
+	}
+	r := &http.Request{
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Proto:      "HTTP/1.1",
+		Method:     method,
+		URL:        &url.URL{Path: h.requestPath(method, req.Path), RawQuery: query.Encode()},
+		Header:     headers,
+		Host:       h.host(headers, req.RequestContext.DomainName),
+		RemoteAddr: req.RequestContext.Identity.SourceIP,
+	}
+	h.setProto(r, req.RequestContext.Protocol)
+	setConn(r, true)
+	ctx = context.WithValue(ctx, v1EventKey, req)
+	r = r.WithContext(h.requestContext(ctx, req.RequestContext.RequestID, headers))
+	setPathValues(r, req.PathParameters)
+	h.setBody(r, req.Body, req.IsBase64Encoded)
+	return r, nil
+}
+
+// responseV1 converts res to the API Gateway REST API (payload format 1.0)
+// response, which is also used by WebSocket APIs. Set-Cookie headers always
+// go to MultiValueHeaders.
+func (h *lambdaHandler) responseV1(res *response) *events.APIGatewayProxyResponse {
+	out := &events.APIGatewayProxyResponse{
+		StatusCode: res.status,
+		Headers:    make(map[string]string, len(res.header)),
+	}
+	for k, vv := range res.header {
+		// Set-Cookie values cannot be joined, and there is no dedicated
+		// cookies field in this format
+		if len(vv) == 1 && !strings.EqualFold(k, "Set-Cookie") {
+			out.Headers[k] = vv[0]
+			continue
+		}
+		if out.MultiValueHeaders == nil {
+			out.MultiValueHeaders = make(map[string][]string)
+		}
+		out.MultiValueHeaders[k] = append(out.MultiValueHeaders[k], vv...)
+	}
+	out.Body, out.IsBase64Encoded = h.encodeBody(res)
+	return out
+}
@@ -0,0 +1,65 @@
+package apig
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestHandlerV1(t *testing.T) {
+	type result struct {
+		Method, Path, ID string
+		Query            map[string][]string
+		Accept           []string
+		Body             string
+	}
+	for _, tc := range []struct {
+		name string
+		req  *events.APIGatewayProxyRequest
+		want result
+	}{
+		{"multi-value", &events.APIGatewayProxyRequest{
+			HTTPMethod:                      "post",
+			Path:                            "/items/42",
+			PathParameters:                  map[string]string{"id": "42"},
+			Headers:                         map[string]string{"accept": "ignored"},
+			MultiValueHeaders:               map[string][]string{"accept": {"text/html", "application/json"}},
+			QueryStringParameters:           map[string]string{"tag": "b"},
+			MultiValueQueryStringParameters: map[string][]string{"tag": {"a", "b"}, "q": {"x y"}},
+			Body:                            "aGVsbG8=",
+			IsBase64Encoded:                 true,
+		}, result{"POST", "/items/42", "42", map[string][]string{"tag": {"a", "b"}, "q": {"x y"}},
+			[]string{"text/html", "application/json"}, "hello"}},
+		{"single-value", &events.APIGatewayProxyRequest{
+			HTTPMethod:            "GET",
+			Path:                  "/",
+			Headers:               map[string]string{"accept": "text/plain"},
+			QueryStringParameters: map[string]string{"q": "a&b"},
+			Body:                  "plain",
+		}, result{"GET", "/", "", map[string][]string{"q": {"a&b"}}, []string{"text/plain"}, "plain"}},
+	} {
+		var got result
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			got = result{r.Method, r.URL.Path, r.PathValue("id"), r.URL.Query(), r.Header["Accept"], string(b)}
+			w.Header().Add("Vary", "Accept")
+			w.Header().Add("Vary", "Origin")
+			w.WriteHeader(http.StatusCreated)
+		})
+		out, err := HandlerV1(h)(context.Background(), tc.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: handler got %+v, want %+v", tc.name, got, tc.want)
+		}
+		if out.StatusCode != http.StatusCreated ||
+			!reflect.DeepEqual(out.MultiValueHeaders["Vary"], []string{"Accept", "Origin"}) {
+			t.Errorf("%s: got %d with multi-value headers %v", tc.name, out.StatusCode, out.MultiValueHeaders)
+		}
+	}
+}
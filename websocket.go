@@ -0,0 +1,124 @@
+package apig
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// WebSocketHandler returns function suitable to use as an AWS Lambda handler
+// with github.com/aws/aws-lambda-go/lambda package for API Gateway WebSocket
+// API routes.
+//
+// Each event is passed to h as an http.Request with the path set to the
+// route key prefixed with "/", i.e. "/$connect", "/$disconnect",
+// "/$default", or "/sendmessage" for a custom route. Request method is GET
+// for the $connect route (which also carries handshake headers and query),
+// and POST for others; request body is the message. Non-2xx status returned
+// for the $connect route rejects the connection. For routes with two-way
+// communication enabled response body is sent back to the client.
+//
+// Use NewConn to send messages to the client that sent the request, and
+// NewManagementClient to send messages to other connected clients.
+func WebSocketHandler(h http.Handler, opts ...Option) func(context.Context, *events.APIGatewayWebsocketProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	if h == nil {
+		panic("WebSocketHandler called with nil argument")
+	}
+	return newHandler(h, opts).runWebSocket
+}
+
+func (h *lambdaHandler) runWebSocket(ctx context.Context, req *events.APIGatewayWebsocketProxyRequest) (*events.APIGatewayProxyResponse, error) {
+	atomic.AddUint64(&invocations, 1)
+	method := http.MethodPost
+	if req.HTTPMethod != "" {
+		var ok bool
+		if method, ok = normalizeMethod(req.HTTPMethod, h.extraMethods); !ok {
+			return h.responseV1(errorResponse(http.StatusBadRequest)), nil
+		}
+	}
+	headers := singleValueHeaders(req.Headers)
+	for k, vv := range req.MultiValueHeaders {
+		headers[http.CanonicalHeaderKey(k)] = vv
+	}
+	query := make(url.Values, len(req.QueryStringParameters))
+	for k, v := range req.QueryStringParameters {
+		query.Set(k, v)
+	}
+	for k, vv := range req.MultiValueQueryStringParameters {
+		query[k] = vv
+	}
+	r := &http.Request{
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Proto:      "HTTP/1.1",
+		Method:     method,
+		URL:        &url.URL{Path: "/" + req.RequestContext.RouteKey, RawQuery: query.Encode()},
+		Header:     headers,
+		Host:       req.RequestContext.DomainName,
+	}
+	h.setProto(r, "")
+	setConn(r, true)
+	ctx = context.WithValue(ctx, wsEventKey, req)
+	ctx = h.requestContext(ctx, req.RequestContext.RequestID, headers)
+	if h.managementAPI != nil {
+		ctx = context.WithValue(ctx, managementAPIKey, h.managementAPI)
+	}
+	r = r.WithContext(ctx)
+	h.setBody(r, req.Body, req.IsBase64Encoded)
+	res := h.serve(r)
+	defer res.release()
+	if res.err != nil {
+		return nil, res.err
+	}
+	return h.responseV1(res), nil
+}
+
+// ConnectionID returns WebSocket connection ID of the request created by
+// WebSocketHandler, or an empty string for other requests.
+func ConnectionID(ctx context.Context) string {
+	if evt, ok := ctx.Value(wsEventKey).(*events.APIGatewayWebsocketProxyRequest); ok {
+		return evt.RequestContext.ConnectionID
+	}
+	return ""
+}
+
+// Conn is a WebSocket connection of the client that sent the request, see
+// NewConn.
+type Conn struct {
+	client *ManagementClient
+	id     string
+}
+
+// NewConn returns connection of the client that sent request r, which must
+// be created by WebSocketHandler. Messages can be sent to the client over it
+// while the request is being served, and afterwards, as long as the client
+// stays connected. Context ctx is used for all calls made over connection.
+func NewConn(ctx context.Context, r *http.Request) (*Conn, error) {
+	c, err := NewManagementClient(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{client: c, id: ConnectionID(r.Context())}, nil
+}
+
+// ID returns connection ID.
+func (c *Conn) ID() string { return c.id }
+
+// Send sends data to the client as a single message.
+func (c *Conn) Send(data []byte) error { return c.client.Send(c.id, data) }
+
+// Write sends p to the client as a single message. It allows using Conn with
+// functions writing to io.Writer, but note that each call is a separate
+// message.
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.client.Send(c.id, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close disconnects the client.
+func (c *Conn) Close() error { return c.client.Disconnect(c.id) }
@@ -0,0 +1,136 @@
+package apig
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// fakeManagementAPI records calls made over ManagementAPI.
+type fakeManagementAPI struct {
+	calls []string
+}
+
+func (api *fakeManagementAPI) PostToConnection(ctx context.Context, endpoint, connectionID string, data []byte) error {
+	api.calls = append(api.calls, "post "+endpoint+" "+connectionID+" "+string(data))
+	return nil
+}
+
+func (api *fakeManagementAPI) DeleteConnection(ctx context.Context, endpoint, connectionID string) error {
+	api.calls = append(api.calls, "delete "+endpoint+" "+connectionID)
+	return nil
+}
+
+func TestManagementClient(t *testing.T) {
+	for _, tc := range []struct {
+		region, wantEndpoint string
+	}{
+		{"", "https://chat.example.com/prod"},
+		{"eu-west-1", "https://abc123.execute-api.eu-west-1.amazonaws.com/prod"},
+	} {
+		t.Setenv("AWS_REGION", tc.region)
+		api := &fakeManagementAPI{}
+		var endpoint string
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c, err := NewManagementClient(context.Background(), r)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			endpoint = c.Endpoint()
+			c.Send("other", []byte("hi"))
+			c.Send(ConnectionID(r.Context()), []byte("hello"))
+			c.Disconnect(ConnectionID(r.Context()))
+		})
+		req := &events.APIGatewayWebsocketProxyRequest{Body: "msg"}
+		req.RequestContext.RouteKey = "sendmessage"
+		req.RequestContext.ConnectionID = "self"
+		req.RequestContext.DomainName = "chat.example.com"
+		req.RequestContext.Stage = "prod"
+		req.RequestContext.APIID = "abc123"
+		if _, err := WebSocketHandler(h, WithManagementAPI(api))(context.Background(), req); err != nil {
+			t.Fatal(err)
+		}
+		if endpoint != tc.wantEndpoint {
+			t.Errorf("AWS_REGION=%q: got endpoint %q, want %q", tc.region, endpoint, tc.wantEndpoint)
+		}
+		want := []string{
+			"post " + tc.wantEndpoint + " other hi",
+			"post " + tc.wantEndpoint + " self hello",
+			"delete " + tc.wantEndpoint + " self",
+		}
+		if !reflect.DeepEqual(api.calls, want) {
+			t.Errorf("AWS_REGION=%q: got calls %q, want %q", tc.region, api.calls, want)
+		}
+	}
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	if _, err := NewManagementClient(context.Background(), r); err == nil {
+		t.Error("NewManagementClient for a non-WebSocket request: got nil error")
+	}
+}
+
+func TestWebSocketHandler(t *testing.T) {
+	type result struct {
+		Method, Path, Token, Proto, Body, ConnID, ConnIDFromConn string
+	}
+	var got result
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = result{r.Method, r.URL.Path, r.URL.Query().Get("token"), r.Header.Get("Sec-Websocket-Protocol"),
+			string(b), ConnectionID(r.Context()), ""}
+		if conn, err := NewConn(context.Background(), r); err == nil {
+			got.ConnIDFromConn = conn.ID()
+		}
+		switch {
+		case r.URL.Path == "/$connect" && got.Token != "secret":
+			w.WriteHeader(http.StatusForbidden)
+		case r.URL.Path == "/echo":
+			w.Write(b)
+		}
+	})
+	fn := WebSocketHandler(h, WithManagementAPI(&fakeManagementAPI{}))
+	for _, tc := range []struct {
+		name     string
+		req      *events.APIGatewayWebsocketProxyRequest
+		want     result
+		code     int
+		wantBody string
+	}{
+		{"connect", &events.APIGatewayWebsocketProxyRequest{
+			HTTPMethod:            "GET",
+			Headers:               map[string]string{"Sec-WebSocket-Protocol": "chat"},
+			QueryStringParameters: map[string]string{"token": "secret"},
+		}, result{"GET", "/$connect", "secret", "chat", "", "c1", "c1"}, http.StatusOK, ""},
+		{"connect rejected", &events.APIGatewayWebsocketProxyRequest{HTTPMethod: "GET"},
+			result{"GET", "/$connect", "", "", "", "c1", "c1"}, http.StatusForbidden, ""},
+		{"message", &events.APIGatewayWebsocketProxyRequest{Body: `{"action":"echo"}`},
+			result{"POST", "/echo", "", "", `{"action":"echo"}`, "c1", "c1"}, http.StatusOK, `{"action":"echo"}`},
+		{"disconnect", &events.APIGatewayWebsocketProxyRequest{},
+			result{"POST", "/$disconnect", "", "", "", "c1", "c1"}, http.StatusOK, ""},
+	} {
+		tc.req.RequestContext.RouteKey = strings.TrimPrefix(tc.want.Path, "/")
+		tc.req.RequestContext.ConnectionID = "c1"
+		tc.req.RequestContext.DomainName = "chat.example.com"
+		tc.req.RequestContext.Stage = "prod"
+		got = result{}
+		out, err := fn(context.Background(), tc.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: handler got %+v, want %+v", tc.name, got, tc.want)
+		}
+		if out.StatusCode != tc.code || strings.TrimSpace(out.Body) != tc.wantBody {
+			t.Errorf("%s: got %d %q, want %d %q", tc.name, out.StatusCode, out.Body, tc.code, tc.wantBody)
+		}
+	}
+	if ConnectionID(context.Background()) != "" {
+		t.Error("outside of request: got non-empty connection ID")
+	}
+}
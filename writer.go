@@ -0,0 +1,244 @@
+package apig
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// responseWriter is http.ResponseWriter buffering the response, which serve
+// passes to the handler. It follows net/http server semantics where they
+// matter for buffered responses:
+//
+//   - headers are snapshotted once the header is written, either explicitly
+//     or implicitly by the first Write, ReadFrom or Flush call;
+//   - Content-Type is detected from the first non-empty write, unless it is
+//     set by the handler, or the default one is configured with
+//     WithDefaultContentType;
+//   - informational 1xx status codes other than 101 are ignored, since
+//     there is no connection to send them to;
+//   - body is discarded with http.ErrBodyNotAllowed for statuses that do
+//     not allow it, and writes over the declared Content-Length fail with
+//     http.ErrContentLength.
+//
+// It implements io.ReaderFrom, and http.Flusher which does nothing, since
+// response is fully buffered.
+type responseWriter struct {
+	header      http.Header
+	snapshot    http.Header // header at the time it was written
+	body        *bytes.Buffer
+	code        int
+	wroteHeader bool
+	written     int64 // body bytes written
+	limit       int64 // declared Content-Length, or -1
+	contentType string
+}
+
+// newResponseWriter returns responseWriter writing body to buf, setting
+// Content-Type to contentType if handler does not set it.
+func newResponseWriter(buf *bytes.Buffer, contentType string) *responseWriter {
+	return &responseWriter{
+		header:      make(http.Header),
+		body:        buf,
+		code:        http.StatusOK,
+		limit:       -1,
+		contentType: contentType,
+	}
+}
+
+func (w *responseWriter) Header() http.Header { return w.header }
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if code < 100 || code > 999 {
+		panic(fmt.Sprintf("invalid WriteHeader code %v", code))
+	}
+	if code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols {
+		return
+	}
+	if w.contentType != "" && bodyAllowed(code) {
+		if _, ok := w.header["Content-Type"]; !ok {
+			w.header.Set("Content-Type", w.contentType)
+		}
+	}
+	w.writeHeader(code)
+}
+
+// writeHeader records the status and takes snapshot of headers.
+func (w *responseWriter) writeHeader(code int) {
+	w.code = code
+	w.wroteHeader = true
+	w.snapshot = w.header.Clone()
+	if cl := w.snapshot.Get("Content-Length"); cl != "" {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && n >= 0 {
+			w.limit = n
+		}
+	}
+}
+
+// implicitHeader writes the header with 200 status on the first write of p.
+func (w *responseWriter) implicitHeader(p []byte) {
+	if w.wroteHeader || len(p) == 0 {
+		return
+	}
+	if _, ok := w.header["Content-Type"]; !ok && w.header.Get("Transfer-Encoding") == "" {
+		ct := w.contentType
+		if ct == "" {
+			ct = http.DetectContentType(p)
+		}
+		w.header.Set("Content-Type", ct)
+	}
+	w.writeHeader(http.StatusOK)
+}
+
+// allowed returns how many of n bytes can be written to the body, and the
+// error to report if not all of them.
+func (w *responseWriter) allowed(n int) (int, error) {
+	if !bodyAllowed(w.code) {
+		return 0, http.ErrBodyNotAllowed
+	}
+	if w.limit >= 0 && w.written+int64(n) > w.limit {
+		return int(w.limit - w.written), http.ErrContentLength
+	}
+	return n, nil
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.implicitHeader(p)
+	n, err := w.allowed(len(p))
+	w.body.Write(p[:n])
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	if !w.wroteHeader && len(s) != 0 {
+		if len(s) > 512 {
+			w.implicitHeader([]byte(s[:512]))
+		} else {
+			w.implicitHeader([]byte(s))
+		}
+	}
+	n, err := w.allowed(len(s))
+	w.body.WriteString(s[:n])
+	w.written += int64(n)
+	return n, err
+}
+
+// ReadFrom copies src right into the body buffer, once the header is
+// written.
+func (w *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	var total int64
+	if !w.wroteHeader {
+		// first bytes are needed to detect content type
+		var sniff [512]byte
+		n, err := io.ReadFull(src, sniff[:])
+		if n != 0 {
+			m, werr := w.Write(sniff[:n])
+			total += int64(m)
+			if werr != nil {
+				return total, werr
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+	if !bodyAllowed(w.code) || w.limit >= 0 {
+		n, err := io.Copy(writerOnly{w}, src)
+		return total + n, err
+	}
+	n, err := w.body.ReadFrom(src)
+	w.written += n
+	return total + n, err
+}
+
+// writerOnly hides ReadFrom method of responseWriter from io.Copy.
+type writerOnly struct{ io.Writer }
+
+// Flush writes the header if it is not written yet; response is only sent
+// once the handler returns.
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// result returns status code, headers and trailers of the response.
+func (w *responseWriter) result() (int, http.Header, http.Header) {
+	header := w.snapshot
+	if header == nil {
+		header = w.header.Clone()
+	}
+	var trailer http.Header
+	for _, v := range header["Trailer"] {
+		for _, k := range strings.Split(v, ",") {
+			k = http.CanonicalHeaderKey(strings.TrimSpace(k))
+			if forbiddenTrailers[k] {
+				continue
+			}
+			if vv, ok := w.header[k]; ok {
+				if trailer == nil {
+					trailer = make(http.Header)
+				}
+				trailer[k] = append([]string(nil), vv...)
+			}
+		}
+	}
+	for k, vv := range w.header {
+		if !strings.HasPrefix(k, http.TrailerPrefix) {
+			continue
+		}
+		if trailer == nil {
+			trailer = make(http.Header)
+		}
+		for _, v := range vv {
+			trailer.Add(strings.TrimPrefix(k, http.TrailerPrefix), v)
+		}
+	}
+	return w.code, header, trailer
+}
+
+// forbiddenTrailers are headers which must not be sent as trailers, see RFC
+// 7230, section 4.1.2.
+var forbiddenTrailers = map[string]bool{
+	"Authorization":       true,
+	"Cache-Control":       true,
+	"Connection":          true,
+	"Content-Encoding":    true,
+	"Content-Length":      true,
+	"Content-Range":       true,
+	"Content-Type":        true,
+	"Expect":              true,
+	"Host":                true,
+	"Keep-Alive":          true,
+	"Max-Forwards":        true,
+	"Pragma":              true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Range":               true,
+	"Realm":               true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Www-Authenticate":    true,
+}
+
+// bodyAllowed reports whether response with the status code can have a
+// body.
+func bodyAllowed(code int) bool {
+	switch {
+	case code >= 100 && code <= 199, code == http.StatusNoContent, code == http.StatusNotModified:
+		return false
+	}
+	return true
+}
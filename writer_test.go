@@ -0,0 +1,89 @@
+package apig
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriter(t *testing.T) {
+	long := strings.Repeat("<html>", 200)
+	for _, tc := range []struct {
+		name     string
+		fn       func(w *responseWriter) error
+		code     int
+		body     string
+		wantCT   string
+		wantErr  error
+		wantHdrs map[string]string
+	}{
+		{"implicit header", func(w *responseWriter) error {
+			_, err := w.Write([]byte("<html>hi"))
+			return err
+		}, http.StatusOK, "<html>hi", "text/html; charset=utf-8", nil, nil},
+		{"headers snapshot", func(w *responseWriter) error {
+			w.Header().Set("X-Before", "1")
+			w.WriteHeader(http.StatusAccepted)
+			w.Header().Set("X-After", "1")
+			w.WriteHeader(http.StatusInternalServerError)
+			return nil
+		}, http.StatusAccepted, "", "", nil, map[string]string{"X-Before": "1", "X-After": ""}},
+		{"informational ignored", func(w *responseWriter) error {
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusCreated)
+			_, err := io.WriteString(w, "ok")
+			return err
+		}, http.StatusCreated, "ok", "", nil, nil},
+		{"body not allowed", func(w *responseWriter) error {
+			w.WriteHeader(http.StatusNoContent)
+			_, err := w.Write([]byte("x"))
+			return err
+		}, http.StatusNoContent, "", "", http.ErrBodyNotAllowed, nil},
+		{"declared length", func(w *responseWriter) error {
+			w.Header().Set("Content-Length", "3")
+			_, err := io.WriteString(w, "abcde")
+			return err
+		}, http.StatusOK, "abc", "text/plain; charset=utf-8", http.ErrContentLength, map[string]string{"Content-Length": "3"}},
+		{"read from", func(w *responseWriter) error {
+			_, err := io.Copy(w, strings.NewReader(long))
+			return err
+		}, http.StatusOK, long, "text/html; charset=utf-8", nil, nil},
+		{"read from with declared length", func(w *responseWriter) error {
+			w.Header().Set("Content-Length", "600")
+			_, err := w.ReadFrom(strings.NewReader(long))
+			return err
+		}, http.StatusOK, long[:600], "text/html; charset=utf-8", http.ErrContentLength, nil},
+		{"flush", func(w *responseWriter) error {
+			w.Flush()
+			w.Header().Set("X-After", "1")
+			return nil
+		}, http.StatusOK, "", "", nil, map[string]string{"X-After": ""}},
+	} {
+		var buf bytes.Buffer
+		w := newResponseWriter(&buf, "")
+		if err := tc.fn(w); !errors.Is(err, tc.wantErr) {
+			t.Errorf("%s: got error %v, want %v", tc.name, err, tc.wantErr)
+		}
+		code, header, _ := w.result()
+		if code != tc.code || buf.String() != tc.body || header.Get("Content-Type") != tc.wantCT {
+			t.Errorf("%s: got %d %.20q... with Content-Type %q, want %d %.20q... with %q", tc.name,
+				code, buf.String(), header.Get("Content-Type"), tc.code, tc.body, tc.wantCT)
+		}
+		for k, v := range tc.wantHdrs {
+			if got := header.Get(k); got != v {
+				t.Errorf("%s: got %s %q, want %q", tc.name, k, got, v)
+			}
+		}
+	}
+
+	var w http.ResponseWriter = newResponseWriter(new(bytes.Buffer), "")
+	if _, ok := w.(io.ReaderFrom); !ok {
+		t.Error("responseWriter does not implement io.ReaderFrom")
+	}
+	if _, ok := w.(http.Flusher); !ok {
+		t.Error("responseWriter does not implement http.Flusher")
+	}
+}